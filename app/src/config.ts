@@ -1 +1,6 @@
-export const WS_URL = import.meta.env.VITE_WS_URL;
+const AUTH_TOKEN = import.meta.env.VITE_AUTH_TOKEN;
+
+// browsers can't set an Authorization header on a WebSocket, so the token goes in the query
+export const WS_URL = AUTH_TOKEN
+  ? `${import.meta.env.VITE_WS_URL}?access_token=${encodeURIComponent(AUTH_TOKEN)}`
+  : import.meta.env.VITE_WS_URL;
@@ -1,5 +1,5 @@
 import { WS_URL } from '@/config';
-import { useEffect, useState } from 'react';
+import { useEffect, useRef, useState } from 'react';
 
 export type ChatMessage = {
   content?: string;
@@ -7,36 +7,120 @@ export type ChatMessage = {
   sessionId?: string;
   error?: string;
   isProcessing?: boolean;
+  seq?: number;
+  // position in the server transcript, set once the conversation has been synced
+  index?: number;
 };
 
+export type BranchSummary = {
+  id: string;
+  index: number;
+  preview: string;
+  message_count: number;
+};
+
+export type PlanStep = {
+  number: number;
+  description: string;
+  tool?: string;
+  files?: string[];
+  branch?: string;
+  commit_message?: string;
+  status: 'pending' | 'done' | 'diverged' | 'failed';
+  note?: string;
+};
+
+export type Plan = {
+  id: string;
+  summary: string;
+  repo?: string;
+  branch?: string;
+  steps: PlanStep[];
+  unplanned?: { tool: string; target?: string }[];
+  status: 'proposed' | 'approved' | 'completed';
+};
+
+export type Persona = {
+  name: string;
+  description: string;
+  builtin: boolean;
+};
+
+type Transcript = {
+  dry_run?: boolean;
+  messages: { role: string; content?: string }[];
+  branches: BranchSummary[];
+  plan?: Plan;
+};
+
+// only user messages and assistant replies with text are shown, tool traffic is hidden
+function transcriptToMessages(transcript: Transcript): ChatMessage[] {
+  const messages: ChatMessage[] = [];
+  transcript.messages.forEach((m, index) => {
+    if (m.role === 'user') messages.push({ content: m.content, index });
+    if (m.role === 'assistant' && m.content) messages.push({ response: m.content, index });
+  });
+  return messages;
+}
+
 export function useWebSocket() {
   const [socket, setSocket] = useState<WebSocket | null>(null);
   const [messages, setMessages] = useState<ChatMessage[]>([]);
   const [sessionId, setSessionId] = useState<string>('');
   const [connected, setConnected] = useState(false);
   const [processingIndex, setProcessingIndex] = useState<number | null>(null);
+  const [branches, setBranches] = useState<BranchSummary[]>([]);
+  const [dryRun, setDryRunState] = useState(false);
+  const [plan, setPlan] = useState<Plan | null>(null);
+  const [personas, setPersonas] = useState<Persona[]>([]);
+  const [persona, setPersonaState] = useState('default');
+
+  // last frame seen, so a reconnect can ask the server to replay only what was missed
+  const lastSeqRef = useRef(0);
+  const sessionIdRef = useRef('');
 
   useEffect(() => {
-    const ws = new WebSocket(WS_URL);
-    
-    ws.onopen = () => {
-      console.log('Connected to chat server');
-      setConnected(true);
-      setSocket(ws);
-    };
-    
-    ws.onclose = () => {
-      console.log('Disconnected from chat server');
-      setConnected(false);
-      setSocket(null);
-    };
-    
-    ws.onerror = (error) => {
-      console.error('WebSocket error:', error);
-      setConnected(false);
+    let ws: WebSocket;
+    let retryTimer: number | undefined;
+    let attempts = 0;
+    let closed = false;
+
+    const connect = () => {
+      ws = new WebSocket(WS_URL);
+
+      ws.onopen = () => {
+        console.log('Connected to chat server');
+        attempts = 0;
+        setConnected(true);
+        setSocket(ws);
+        ws.send(JSON.stringify({ command: 'list_personas' }));
+
+        if (sessionIdRef.current) {
+          ws.send(JSON.stringify({ session_id: sessionIdRef.current, last_seq: lastSeqRef.current }));
+        }
+      };
+
+      ws.onclose = () => {
+        console.log('Disconnected from chat server');
+        setConnected(false);
+        setSocket(null);
+
+        if (!closed) {
+          retryTimer = window.setTimeout(connect, Math.min(1000 * 2 ** attempts++, 10000));
+        }
+      };
+
+      ws.onerror = (error) => {
+        console.error('WebSocket error:', error);
+        setConnected(false);
+      };
     };
-    
+
+    connect();
+
     return () => {
+      closed = true;
+      window.clearTimeout(retryTimer);
       ws.close();
     };
   }, []);
@@ -47,13 +131,57 @@ export function useWebSocket() {
     const handleMessage = (event: MessageEvent) => {
       try {
         const messageRaw = JSON.parse(event.data);
+
+        if (messageRaw.seq) {
+          // replayed frames we already have
+          if (messageRaw.seq <= lastSeqRef.current) return;
+          lastSeqRef.current = messageRaw.seq;
+        } else if (sessionIdRef.current && !messageRaw.error && !messageRaw.command) {
+          // the greeting is sent again on every reconnect
+          return;
+        }
+
         const message: ChatMessage = {
           ...messageRaw,
           sessionId: messageRaw.session_id,
           isProcessing: messageRaw.is_processing,
         };
         
-        if (message.sessionId) setSessionId(message.sessionId);
+        if (message.sessionId) {
+          sessionIdRef.current = message.sessionId;
+          setSessionId(message.sessionId);
+        }
+
+        if (messageRaw.command === 'transcript' || messageRaw.command === 'get_transcript' || messageRaw.command === 'switch_branch') {
+          if (messageRaw.data) {
+            setMessages(transcriptToMessages(messageRaw.data));
+            setBranches(messageRaw.data.branches ?? []);
+            setPlan(messageRaw.data.plan ?? null);
+            setDryRunState(!!messageRaw.data.dry_run);
+            setProcessingIndex(null);
+          }
+          return;
+        }
+        if ((messageRaw.command === 'plan' || messageRaw.command === 'approve_plan') && messageRaw.data) {
+          setPlan(messageRaw.data);
+          return;
+        }
+        if (messageRaw.command === 'set_dry_run' && messageRaw.data) {
+          setDryRunState(!!messageRaw.data.dry_run);
+          return;
+        }
+        if (messageRaw.command === 'list_personas' && messageRaw.data) {
+          setPersonas(messageRaw.data);
+          return;
+        }
+        if (messageRaw.command === 'set_persona' && messageRaw.data) {
+          setPersonaState(messageRaw.data.persona || 'default');
+          return;
+        }
+        if (messageRaw.command) {
+          if (messageRaw.error) console.error(`${messageRaw.command} failed:`, messageRaw.error);
+          return;
+        }
         
         if (message.isProcessing) {
           setMessages((prev) => {
@@ -72,6 +200,11 @@ export function useWebSocket() {
             return [...filteredMessages, message];
           });
           setProcessingIndex(null);
+
+          // sync with the server so edits and branches line up with the transcript
+          if (message.sessionId && message.response) {
+            socket.send(JSON.stringify({ command: 'get_transcript', session_id: message.sessionId }));
+          }
         }
       } catch (error) {
         console.error('Error handling message:', error);
@@ -95,5 +228,55 @@ export function useWebSocket() {
     }
   };
 
-  return { messages, sendMessage, connected };
+  const startRun = (command: object, optimistic: ChatMessage[]) => {
+    if (socket && connected && sessionId) {
+      socket.send(JSON.stringify({ ...command, session_id: sessionId }));
+      setMessages([...optimistic, { isProcessing: true, response: "Thinking..." }]);
+      setProcessingIndex(optimistic.length);
+    }
+  };
+
+  const editMessage = (index: number, content: string) => {
+    const kept = messages.filter((m) => m.index !== undefined && m.index < index);
+    startRun({ command: 'edit_message', args: { index, content } }, [...kept, { content, index }]);
+  };
+
+  const regenerate = () => {
+    const lastUser = messages.map((m) => !!m.content).lastIndexOf(true);
+    if (lastUser === -1) return;
+    startRun({ command: 'regenerate' }, messages.slice(0, lastUser + 1));
+  };
+
+  const switchBranch = (branchId: string) => {
+    if (socket && connected && sessionId) {
+      socket.send(JSON.stringify({ command: 'switch_branch', session_id: sessionId, args: { branch_id: branchId } }));
+    }
+  };
+
+  const approvePlan = () => {
+    startRun({ command: 'approve_plan' }, messages);
+  };
+
+  // reverts the branches, commits and PRs of the last run; the report arrives as a message
+  const undo = () => {
+    if (socket && connected && sessionId) {
+      socket.send(JSON.stringify({ command: 'undo', session_id: sessionId }));
+    }
+  };
+
+  // write tools only simulate their changes while this is on
+  const setDryRun = (enabled: boolean) => {
+    if (socket && connected && sessionId) {
+      socket.send(JSON.stringify({ command: 'set_dry_run', session_id: sessionId, args: { enabled } }));
+    }
+  };
+
+  // applies from the next message on
+  const setPersona = (name: string) => {
+    if (socket && connected && sessionId) {
+      socket.send(JSON.stringify({ command: 'set_persona', session_id: sessionId, args: { persona: name } }));
+    }
+  };
+
+  return { messages, sendMessage, connected, branches, editMessage, regenerate, switchBranch, personas, persona, setPersona, plan, approvePlan, dryRun, setDryRun, undo };
 }
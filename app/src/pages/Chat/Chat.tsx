@@ -8,7 +8,9 @@ import { ScrollArea } from '@/components/ui/scroll-area';
 
 export default function ChatPage() {
   const [inputValue, setInputValue] = useState('');
-  const { messages, sendMessage, connected } = useWebSocket();
+  const { messages, sendMessage, connected, branches, editMessage, regenerate, switchBranch, personas, persona, setPersona, plan, approvePlan, dryRun, setDryRun, undo } = useWebSocket();
+  const [editingIndex, setEditingIndex] = useState<number | null>(null);
+  const [editValue, setEditValue] = useState('');
   const messagesEndRef = useRef<HTMLDivElement>(null);
   const textareaRef = useRef<HTMLTextAreaElement>(null);
   
@@ -18,6 +20,29 @@ export default function ChatPage() {
     return processingMessages.length > 0 ? processingMessages[processingMessages.length - 1] : null;
   }, [messages]);
 
+  // a branch shows up on the first visible message at or after the point where it diverges
+  const branchesByMessage = useMemo(() => {
+    const anchored = new Map<number, typeof branches>();
+    for (const branch of branches) {
+      const anchor = messages.find((m) => m.index !== undefined && m.index >= branch.index);
+      if (anchor?.index === undefined) continue;
+      anchored.set(anchor.index, [...(anchored.get(anchor.index) ?? []), branch]);
+    }
+    return anchored;
+  }, [messages, branches]);
+
+  const lastResponseIndex = useMemo(
+    () => messages.map((m) => !m.isProcessing && !!m.response && m.index !== undefined).lastIndexOf(true),
+    [messages],
+  );
+
+  const handleSaveEdit = () => {
+    if (editingIndex !== null && editValue.trim()) {
+      editMessage(editingIndex, editValue);
+    }
+    setEditingIndex(null);
+  };
+
   useEffect(() => {
     messagesEndRef.current?.scrollIntoView({ behavior: 'smooth' });
   }, [messages]);
@@ -78,14 +103,35 @@ export default function ChatPage() {
                   >
                     {message.content ? (
                       /* User message - right aligned with background */
-                      <div className="flex justify-end">
-                        <div className="bg-primary text-primary-foreground px-4 py-3 rounded-xl max-w-[80%]">
-                          <div className="whitespace-pre-wrap">{message.content}</div>
-                        </div>
+                      <div className="flex flex-col items-end gap-1">
+                        {editingIndex !== null && editingIndex === message.index ? (
+                          <div className="w-[80%] flex flex-col gap-2">
+                            <Textarea value={editValue} onChange={(e) => setEditValue(e.target.value)} rows={3} />
+                            <div className="flex justify-end gap-2">
+                              <Button size="sm" variant="ghost" onClick={() => setEditingIndex(null)}>Cancel</Button>
+                              <Button size="sm" onClick={handleSaveEdit} disabled={!editValue.trim()}>Save & rerun</Button>
+                            </div>
+                          </div>
+                        ) : (
+                          <div className="bg-primary text-primary-foreground px-4 py-3 rounded-xl max-w-[80%]">
+                            <div className="whitespace-pre-wrap">{message.content}</div>
+                          </div>
+                        )}
+                        {message.index !== undefined && editingIndex === null && !processingMessage && (
+                          <button
+                            className="text-xs text-muted-foreground hover:text-foreground"
+                            onClick={() => {
+                              setEditingIndex(message.index!);
+                              setEditValue(message.content ?? '');
+                            }}
+                          >
+                            Edit
+                          </button>
+                        )}
                       </div>
                     ) : (
                       /* AI message - left aligned */
-                      <div className="flex">
+                      <div className="flex flex-col items-start gap-1">
                         <div className="max-w-[80%]">
                           {message.response ? (
                             <MarkdownRenderer content={message.response} />
@@ -93,12 +139,68 @@ export default function ChatPage() {
                             <div className="text-destructive">{message.error || "An error occurred"}</div>
                           )}
                         </div>
+                        {index === lastResponseIndex && !processingMessage && (
+                          <button className="text-xs text-muted-foreground hover:text-foreground" onClick={regenerate}>
+                            Regenerate
+                          </button>
+                        )}
+                      </div>
+                    )}
+                    {message.index !== undefined && branchesByMessage.has(message.index) && !processingMessage && (
+                      /* alternates of the conversation from this point on */
+                      <div className={`flex gap-2 mt-1 text-xs text-muted-foreground ${message.content ? 'justify-end' : ''}`}>
+                        <span>{branchesByMessage.get(message.index)!.length + 1} versions</span>
+                        {branchesByMessage.get(message.index)!.map((branch) => (
+                          <button
+                            key={branch.id}
+                            className="hover:text-foreground underline-offset-2 hover:underline"
+                            title={branch.preview}
+                            onClick={() => switchBranch(branch.id)}
+                          >
+                            {branch.preview.length > 24 ? `${branch.preview.slice(0, 24)}…` : branch.preview || 'other version'}
+                          </button>
+                        ))}
                       </div>
                     )}
                   </div>
                 );
               })}
               
+              {plan && (
+                /* the structured plan, checked off as the agent executes it */
+                <div className="mb-6 border border-border rounded-lg p-4 text-sm">
+                  <div className="flex items-center justify-between mb-2">
+                    <span className="font-medium">Plan{plan.branch ? ` · ${plan.branch}` : ''}</span>
+                    <span className="text-xs text-muted-foreground">{plan.status}</span>
+                  </div>
+                  {plan.summary && <p className="text-muted-foreground mb-2">{plan.summary}</p>}
+                  <ul className="space-y-1">
+                    {plan.steps.map((step) => (
+                      <li key={step.number} className="flex gap-2" title={step.note}>
+                        <span className="w-4 shrink-0">
+                          {{ pending: '○', done: '✓', diverged: '↯', failed: '✗' }[step.status]}
+                        </span>
+                        <span className={step.status === 'done' ? 'text-muted-foreground line-through' : ''}>
+                          {step.description}
+                          {step.files?.length ? <span className="text-xs text-muted-foreground"> ({step.files.join(', ')})</span> : null}
+                          {step.note && <span className="block text-xs text-muted-foreground">{step.note}</span>}
+                        </span>
+                      </li>
+                    ))}
+                  </ul>
+                  {plan.unplanned?.length ? (
+                    <p className="text-xs text-muted-foreground mt-2">
+                      Not in the plan: {plan.unplanned.map((call) => `${call.tool}${call.target ? ` (${call.target})` : ''}`).join(', ')}
+                    </p>
+                  ) : null}
+                  {plan.status === 'proposed' && (
+                    <Button size="sm" className="mt-3" onClick={approvePlan} disabled={!connected || !!processingMessage}>
+                      Approve plan
+                    </Button>
+                  )}
+                </div>
+              )}
+
               {processingMessage && (
                 <div className="flex mb-6">
                   <div className="max-w-[80%] bg-muted/20 rounded-lg p-4">
@@ -149,6 +251,35 @@ export default function ChatPage() {
             </Button>
           </div>
           <div className="text-xs text-center text-muted-foreground mt-2">
+            {personas.length > 1 && (
+              <select
+                value={persona}
+                onChange={(e) => setPersona(e.target.value)}
+                disabled={!connected}
+                title={personas.find((p) => p.name === persona)?.description}
+                className="mr-2 bg-transparent border border-border rounded px-1 py-0.5"
+              >
+                {personas.map((p) => (
+                  <option key={p.name} value={p.name} title={p.description}>
+                    {p.name}
+                  </option>
+                ))}
+              </select>
+            )}
+            <label className="mr-2 inline-flex items-center gap-1" title="Simulate branches, commits and PRs instead of writing to GitHub">
+              <input type="checkbox" checked={dryRun} onChange={(e) => setDryRun(e.target.checked)} disabled={!connected} />
+              Dry run
+            </label>
+            {messages.length > 0 && (
+              <button
+                className="mr-2 underline-offset-2 hover:underline hover:text-foreground"
+                title="Delete branches, reset commits and close PRs from the last run"
+                onClick={undo}
+                disabled={!connected || !!processingMessage}
+              >
+                Undo last run
+              </button>
+            )}
             Gollama can make mistakes. <span>Powered by gpt-oss</span>
           </div>
         </div>
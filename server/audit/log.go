@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// a line holds arguments and results, which can be big but never this big
+const maxLineBytes = 4 * 1024 * 1024
+
+// Entry is one side effect on a repository: who caused it, in which turn, what was
+// written where and what came of it.
+type Entry struct {
+	Seq       int64          `json:"seq"`
+	Time      time.Time      `json:"time"`
+	User      string         `json:"user"`
+	SessionID string         `json:"session_id,omitempty"`
+	RunID     string         `json:"run_id,omitempty"`
+	Prompt    string         `json:"prompt,omitempty"`
+	Action    string         `json:"action"`
+	Repo      string         `json:"repo,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Result    map[string]any `json:"result,omitempty"`
+	Outcome   string         `json:"outcome"`
+	Error     string         `json:"error,omitempty"`
+	// each entry hashes the one before, so an edited or removed line breaks the chain
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// Log is an append-only JSONL file of entries. Entries are never rewritten, and the
+// hash chain makes tampering with the file detectable.
+type Log struct {
+	path     string
+	file     *os.File
+	lastSeq  int64
+	lastHash string
+	mu       sync.Mutex
+}
+
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{path: path}
+
+	// carry on the chain from the last entry written before a restart
+	err := l.scan(func(entry Entry) bool {
+		l.lastSeq = entry.Seq
+		l.lastHash = entry.Hash
+		return true
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+
+	return l, nil
+}
+
+// Append chains entry onto the log and writes it to disk before returning it.
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.lastSeq + 1
+	entry.Time = time.Now().UTC()
+	entry.PrevHash = l.lastHash
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+	}
+
+	hash, err := entryHash(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return Entry{}, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	l.lastSeq = entry.Seq
+	l.lastHash = entry.Hash
+	return entry, nil
+}
+
+// scan calls fn with every entry in order until fn returns false.
+func (l *Log) scan(fn func(Entry) bool) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to parse audit log %s: %w", l.path, err)
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// entryHash is the SHA-256 of the entry, previous hash included, without its own hash.
+func entryHash(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeLog appends one entry per action to a new log and returns its path.
+func writeLog(t *testing.T, actions ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit", "log.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, action := range actions {
+		if _, err := l.Append(Entry{User: "alice", Action: action, Repo: "acme/demo"}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return path
+}
+
+func TestHashChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(lines [][]byte) [][]byte
+		verified bool
+		brokenAt int64
+	}{
+		{
+			name:     "untouched",
+			tamper:   func(lines [][]byte) [][]byte { return lines },
+			verified: true,
+		},
+		{
+			name: "edited entry",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"user":"alice"`), []byte(`"user":"mallory"`), 1)
+				return lines
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "removed entry",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			brokenAt: 3,
+		},
+		{
+			name: "swapped entries",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			brokenAt: 3,
+		},
+		{
+			// the chain can't tell the newest entries were cut off, only that the rest is intact
+			name: "truncated tail",
+			tamper: func(lines [][]byte) [][]byte {
+				return lines[:2]
+			},
+			verified: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, "create_branch", "update_file", "create_github_pr", "undo")
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+			lines = tt.tamper(lines)
+			if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			result, err := l.Query(Filter{})
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if result.Verified != tt.verified || result.BrokenAt != tt.brokenAt {
+				t.Fatalf("got verified %v broken at %d, want %v at %d", result.Verified, result.BrokenAt, tt.verified, tt.brokenAt)
+			}
+		})
+	}
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	path := writeLog(t, "create_branch", "update_file")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	entry, err := l.Append(Entry{User: "bob", Action: "create_github_pr"})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if entry.Seq != 3 || entry.PrevHash == "" || entry.Outcome != OutcomeSuccess {
+		t.Fatalf("appended %+v after a restart", entry)
+	}
+
+	result, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !result.Verified || len(result.Entries) != 3 {
+		t.Fatalf("got %d entries, verified %v", len(result.Entries), result.Verified)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, entry := range []Entry{
+		{User: "alice", SessionID: "s1", Repo: "acme/demo", Action: "create_branch"},
+		{User: "bob", SessionID: "s2", Repo: "acme/other", Action: "update_file"},
+		{User: "alice", SessionID: "s1", Repo: "acme/demo", Action: "update_file"},
+		{User: "alice", SessionID: "s3", Repo: "acme/other", Action: "create_github_pr"},
+	} {
+		if _, err := l.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		seqs   []int64
+	}{
+		{name: "everything newest first", filter: Filter{}, seqs: []int64{4, 3, 2, 1}},
+		{name: "user", filter: Filter{User: "alice"}, seqs: []int64{4, 3, 1}},
+		{name: "session", filter: Filter{SessionID: "s1"}, seqs: []int64{3, 1}},
+		{name: "repo and action", filter: Filter{Repo: "acme/other", Action: "update_file"}, seqs: []int64{2}},
+		{name: "limit keeps the newest", filter: Filter{User: "alice", Limit: 2}, seqs: []int64{4, 3}},
+		{name: "no match", filter: Filter{User: "carol"}, seqs: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			seqs := make([]int64, 0, len(result.Entries))
+			for _, entry := range result.Entries {
+				seqs = append(seqs, entry.Seq)
+			}
+			if !slices.Equal(seqs, tt.seqs) {
+				t.Fatalf("got %v, want %v", seqs, tt.seqs)
+			}
+		})
+	}
+}
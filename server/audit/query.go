@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// Filter narrows a query. Empty fields match everything.
+type Filter struct {
+	User      string
+	SessionID string
+	Repo      string
+	Action    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (f Filter) matches(entry Entry) bool {
+	switch {
+	case f.User != "" && entry.User != f.User:
+		return false
+	case f.SessionID != "" && entry.SessionID != f.SessionID:
+		return false
+	case f.Repo != "" && entry.Repo != f.Repo:
+		return false
+	case f.Action != "" && entry.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Result holds the newest matching entries first. Verified is false when the hash chain
+// is broken anywhere in the log, and BrokenAt is the first entry that doesn't chain.
+type Result struct {
+	Entries  []Entry `json:"entries"`
+	Verified bool    `json:"verified"`
+	BrokenAt int64   `json:"broken_at,omitempty"`
+}
+
+// Query reads the whole log, checking the hash chain on the way, and returns the
+// entries matching filter.
+func (l *Log) Query(filter Filter) (Result, error) {
+	if filter.Limit < 1 {
+		filter.Limit = DefaultQueryLimit
+	}
+	filter.Limit = min(filter.Limit, MaxQueryLimit)
+
+	// entries appended while reading are left for the next query
+	l.mu.Lock()
+	lastSeq := l.lastSeq
+	l.mu.Unlock()
+
+	result := Result{Entries: []Entry{}, Verified: true}
+	prevHash := ""
+	err := l.scan(func(entry Entry) bool {
+		if entry.Seq > lastSeq {
+			return false
+		}
+
+		if result.Verified {
+			hash, err := entryHash(entry)
+			if err != nil || entry.PrevHash != prevHash || entry.Hash != hash {
+				result.Verified = false
+				result.BrokenAt = entry.Seq
+			}
+			prevHash = entry.Hash
+		}
+
+		if filter.matches(entry) {
+			result.Entries = append(result.Entries, entry)
+			// only the newest entries are kept
+			if len(result.Entries) > filter.Limit {
+				result.Entries = result.Entries[1:]
+			}
+		}
+		return true
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Result{}, err
+	}
+
+	slices.Reverse(result.Entries)
+	return result, nil
+}
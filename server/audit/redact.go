@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// contentFields carry file contents or text bodies. Only their hash and size are kept,
+// enough to match a write to a commit without copying code into the log.
+var contentFields = map[string]bool{
+	"content":  true,
+	"body":     true,
+	"old_text": true,
+	"new_text": true,
+}
+
+// resultFields identify what a side effect produced.
+var resultFields = []string{
+	"sha", "commit_sha", "before_sha", "url", "ref", "branch", "branch_name",
+	"number", "path", "head", "base", "created",
+}
+
+// Arguments decodes a tool's JSON arguments with the contents replaced by their hash.
+func Arguments(raw string) map[string]any {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return map[string]any{"unparsed": Hash(raw)}
+	}
+
+	for key, value := range args {
+		if !contentFields[key] {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			data, _ := json.Marshal(value)
+			text = string(data)
+		}
+		args[key] = Hash(text)
+	}
+	return args
+}
+
+// Hash describes content by its SHA-256 and size.
+func Hash(content string) map[string]any {
+	sum := sha256.Sum256([]byte(content))
+	return map[string]any{
+		"sha256": hex.EncodeToString(sum[:]),
+		"bytes":  len(content),
+	}
+}
+
+// ResultRefs keeps the commit SHAs, URLs and names from a tool's JSON result. Results
+// that aren't JSON objects are hashed.
+func ResultRefs(raw string) map[string]any {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return map[string]any{"unparsed": Hash(raw)}
+	}
+
+	refs := make(map[string]any)
+	for _, key := range resultFields {
+		if value, ok := result[key]; ok {
+			refs[key] = value
+		}
+	}
+	return refs
+}
+
+// Repo reads the owner and repo arguments most tools take.
+func Repo(args map[string]any) string {
+	owner, _ := args["owner"].(string)
+	repo, _ := args["repo"].(string)
+	if owner == "" || repo == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", owner, repo)
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]any
+	}{
+		{
+			name: "content is hashed",
+			raw:  `{"owner": "acme", "repo": "demo", "path": "a.txt", "content": "hello"}`,
+			want: map[string]any{
+				"owner": "acme", "repo": "demo", "path": "a.txt",
+				"content": Hash("hello"),
+			},
+		},
+		{
+			name: "edits are hashed",
+			raw:  `{"path": "a.txt", "old_text": "a", "new_text": "b"}`,
+			want: map[string]any{"path": "a.txt", "old_text": Hash("a"), "new_text": Hash("b")},
+		},
+		{
+			name: "non-string body is hashed as JSON",
+			raw:  `{"title": "Fix", "body": ["a", "b"]}`,
+			want: map[string]any{"title": "Fix", "body": Hash(`["a","b"]`)},
+		},
+		{
+			name: "not JSON",
+			raw:  `content: secret`,
+			want: map[string]any{"unparsed": Hash("content: secret")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Arguments(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Arguments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]any
+	}{
+		{
+			name: "keeps references only",
+			raw:  `{"sha": "abc", "commit_sha": "def", "branch": "main", "diff": "+secret", "content": "code"}`,
+			want: map[string]any{"sha": "abc", "commit_sha": "def", "branch": "main"},
+		},
+		{
+			name: "pull request",
+			raw:  `{"number": 7, "url": "https://github.com/acme/demo/pull/7", "title": "Fix"}`,
+			want: map[string]any{"number": float64(7), "url": "https://github.com/acme/demo/pull/7"},
+		},
+		{
+			name: "not an object",
+			raw:  `Created branch`,
+			want: map[string]any{"unparsed": Hash("Created branch")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResultRefs(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResultRefs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CookieName = "gollama_auth"
+
+	// query parameter carrying the token where headers can't be set
+	TokenParam = "access_token"
+
+	// used for every request when authentication is explicitly disabled
+	AnonymousUser = "anonymous"
+
+	userKey = "auth_user"
+)
+
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+// Authenticator resolves the user behind a request from a bearer token or a signed cookie.
+type Authenticator struct {
+	tokens       map[string]string
+	cookieSecret []byte
+	disabled     bool
+}
+
+// New takes a token to user name map and the secret used to sign cookies. Without
+// either, every request is refused, unless disabled lets them all in as AnonymousUser.
+func New(tokens map[string]string, cookieSecret string, disabled bool) *Authenticator {
+	return &Authenticator{
+		tokens:       tokens,
+		cookieSecret: []byte(cookieSecret),
+		disabled:     disabled,
+	}
+}
+
+// Enabled reports whether tokens or a cookie secret are configured; credentials are
+// checked whenever they are, disabled or not.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0 || len(a.cookieSecret) > 0
+}
+
+// Authenticate checks, in order, the Authorization header, the access_token query
+// parameter (browsers can't set headers on a WebSocket upgrade) and the signed cookie.
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	if !a.Enabled() {
+		if a.disabled {
+			return AnonymousUser, nil
+		}
+		return "", ErrUnauthenticated
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get(TokenParam)
+	}
+	if token != "" {
+		if user, ok := a.lookupToken(token); ok {
+			return user, nil
+		}
+		return "", ErrUnauthenticated
+	}
+
+	if cookie, err := r.Cookie(CookieName); err == nil {
+		return a.verifyCookie(cookie.Value)
+	}
+
+	return "", ErrUnauthenticated
+}
+
+// HasCredentials reports whether r carries a token or cookie at all, whether or not it's
+// valid.
+func HasCredentials(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || r.URL.Query().Get(TokenParam) != "" {
+		return true
+	}
+	_, err := r.Cookie(CookieName)
+	return err == nil
+}
+
+// RedactToken replaces the token in a request path's query with a placeholder, so it
+// doesn't end up in logs.
+func RedactToken(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// can't tell where the token is, keep none of the query
+		return base + "?[unparsable]"
+	}
+	if !query.Has(TokenParam) {
+		return path
+	}
+	query.Set(TokenParam, "REDACTED")
+	return base + "?" + query.Encode()
+}
+
+func (a *Authenticator) lookupToken(token string) (string, bool) {
+	var found string
+	for candidate, user := range a.tokens {
+		// compare every token so timing doesn't reveal a partial match
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			found = user
+		}
+	}
+	return found, found != ""
+}
+
+// SignCookie returns a cookie value binding the user until expiry.
+func (a *Authenticator) SignCookie(user string, expiry time.Time) (string, error) {
+	if len(a.cookieSecret) == 0 {
+		return "", errors.New("no cookie secret configured")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expiry.Unix(), 10)
+	return payload + "." + a.sign(payload), nil
+}
+
+func (a *Authenticator) verifyCookie(value string) (string, error) {
+	if len(a.cookieSecret) == 0 {
+		return "", ErrUnauthenticated
+	}
+
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return "", ErrUnauthenticated
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(a.sign(payload)), []byte(parts[2])) {
+		return "", ErrUnauthenticated
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return "", fmt.Errorf("%w: cookie expired", ErrUnauthenticated)
+	}
+
+	user, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrUnauthenticated
+	}
+
+	return string(user), nil
+}
+
+func (a *Authenticator) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.cookieSecret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware rejects unauthenticated requests and stores the user on the gin context.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := a.Authenticate(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		c.Set(userKey, user)
+		c.Next()
+	}
+}
+
+// User returns the user stored by Middleware.
+func User(c *gin.Context) string {
+	return c.GetString(userKey)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedactToken(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/chat", want: "/chat"},
+		{path: "/chat?session_id=abc", want: "/chat?session_id=abc"},
+		{path: "/chat?access_token=secret", want: "/chat?access_token=REDACTED"},
+		{path: "/chat?session_id=abc&access_token=secret", want: "/chat?access_token=REDACTED&session_id=abc"},
+		{path: "/chat?access_token=a&access_token=b", want: "/chat?access_token=REDACTED"},
+		{path: "/chat?access_token=secret;x=%zz", want: "/chat?[unparsable]"},
+	}
+
+	for _, tt := range tests {
+		if got := RedactToken(tt.path); got != tt.want {
+			t.Errorf("RedactToken(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		cookie bool
+		want   bool
+	}{
+		{name: "nothing", url: "/chat", want: false},
+		{name: "bearer", url: "/chat", header: "Bearer secret", want: true},
+		{name: "basic", url: "/chat", header: "Basic c2VjcmV0", want: false},
+		{name: "query", url: "/chat?access_token=secret", want: true},
+		{name: "empty query", url: "/chat?access_token=", want: false},
+		{name: "cookie", url: "/chat", cookie: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: CookieName, Value: "signed"})
+			}
+			if got := HasCredentials(r); got != tt.want {
+				t.Errorf("HasCredentials = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tokens := map[string]string{"secret": "alice"}
+
+	tests := []struct {
+		name     string
+		tokens   map[string]string
+		disabled bool
+		header   string
+		want     string
+		wantErr  bool
+	}{
+		{name: "nothing configured", wantErr: true},
+		{name: "nothing configured with a token", header: "Bearer secret", wantErr: true},
+		{name: "disabled", disabled: true, want: AnonymousUser},
+		{name: "token", tokens: tokens, header: "Bearer secret", want: "alice"},
+		{name: "wrong token", tokens: tokens, header: "Bearer guess", wantErr: true},
+		{name: "no token", tokens: tokens, wantErr: true},
+		// configured credentials are checked even with authentication disabled
+		{name: "disabled with tokens", tokens: tokens, disabled: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/chat", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			user, err := New(tt.tokens, "", tt.disabled).Authenticate(r)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnauthenticated) {
+					t.Fatalf("got %q, %v, want ErrUnauthenticated", user, err)
+				}
+				return
+			}
+			if err != nil || user != tt.want {
+				t.Fatalf("got %q, %v, want %q", user, err, tt.want)
+			}
+		})
+	}
+}
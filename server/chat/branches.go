@@ -0,0 +1,152 @@
+package chat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var (
+	ErrSessionBusy    = errors.New("session has a run in progress")
+	ErrNotUserMessage = errors.New("message is not a user message")
+	ErrBranchNotFound = errors.New("branch not found")
+	ErrNothingToRedo  = errors.New("no user message to regenerate from")
+)
+
+// Branch is a continuation of the conversation that was replaced by an edit or a
+// regenerate. It diverges from the current path at transcript index Index. Branches
+// that diverged later inside it are kept as its children, so the whole tree survives
+// flipping back and forth.
+type Branch struct {
+	ID        string                         `json:"id"`
+	Index     int                            `json:"index"`
+	Messages  []openai.ChatCompletionMessage `json:"messages"`
+	Children  []Branch                       `json:"children,omitempty"`
+	CreatedAt time.Time                      `json:"created_at"`
+}
+
+type BranchSummary struct {
+	ID           string `json:"id"`
+	Index        int    `json:"index"`
+	Preview      string `json:"preview"`
+	MessageCount int    `json:"message_count"`
+}
+
+// EditMessage replaces the user message at transcript index with content, keeping the
+// old continuation as a branch. The caller re-runs the agent on the new message.
+func (s *ChatSession) EditMessage(index int, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos := index + 1 // skip the system prompt
+	if index < 0 || pos >= len(s.Messages) {
+		return ErrInvalidIndex
+	}
+	if s.Messages[pos].Role != openai.ChatMessageRoleUser {
+		return ErrNotUserMessage
+	}
+
+	s.rewind(index)
+	s.Messages = append(s.Messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: content,
+	})
+	s.LastUsed = time.Now()
+	return nil
+}
+
+// Regenerate drops everything after the last user message, keeping it as a branch,
+// and returns that message so the caller can re-run the agent on it.
+func (s *ChatSession) Regenerate() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for pos := len(s.Messages) - 1; pos > 0; pos-- {
+		if s.Messages[pos].Role == openai.ChatMessageRoleUser {
+			s.rewind(pos)
+			s.LastUsed = time.Now()
+			return s.Messages[pos].Content, nil
+		}
+	}
+
+	return "", ErrNothingToRedo
+}
+
+// SwitchBranch makes the branch the current path and keeps the path it replaces as a branch.
+func (s *ChatSession) SwitchBranch(branchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, branch := range s.Branches {
+		if branch.ID != branchID {
+			continue
+		}
+
+		s.Branches = append(s.Branches[:i:i], s.Branches[i+1:]...)
+		s.rewind(branch.Index)
+		s.Messages = append(s.Messages, branch.Messages...)
+		s.Branches = append(s.Branches, branch.Children...)
+		s.LastUsed = time.Now()
+		return nil
+	}
+
+	return ErrBranchNotFound
+}
+
+// BranchSummaries lists the alternates of the current path.
+func (s *ChatSession) BranchSummaries() []BranchSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summaries := make([]BranchSummary, 0, len(s.Branches))
+	for _, branch := range s.Branches {
+		summary := BranchSummary{
+			ID:           branch.ID,
+			Index:        branch.Index,
+			MessageCount: len(branch.Messages),
+		}
+		if len(branch.Messages) > 0 {
+			summary.Preview = titleFrom(branch.Messages[0].Content)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// rewind truncates the path to the first index transcript messages and stores the cut
+// tail as a branch, together with the branches that diverged inside it.
+// Must be called with s.mu held.
+func (s *ChatSession) rewind(index int) {
+	pos := index + 1
+	if pos >= len(s.Messages) {
+		return
+	}
+
+	tail := Branch{
+		ID:        generateBranchID(),
+		Index:     index,
+		Messages:  append([]openai.ChatCompletionMessage{}, s.Messages[pos:]...),
+		CreatedAt: time.Now(),
+	}
+
+	var kept []Branch
+	for _, branch := range s.Branches {
+		if branch.Index > index {
+			tail.Children = append(tail.Children, branch)
+		} else {
+			kept = append(kept, branch)
+		}
+	}
+
+	s.Branches = append(kept, tail)
+	s.Messages = s.Messages[:pos]
+}
+
+func generateBranchID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
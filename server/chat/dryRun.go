@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"gollama/tools"
+)
+
+// SetDryRun switches the session's dry-run mode. Turning it on starts from a clean
+// simulation, so earlier pretend branches and files are forgotten. The dry runs' edits
+// and commits are thrown away whenever the mode changes. A run in progress keeps the
+// mode it started in, see RunContext.
+func (s *ChatSession) SetDryRun(enabled bool) {
+	s.mu.Lock()
+	changed := enabled != s.DryRun
+	if enabled && !s.DryRun {
+		s.simulation = tools.NewSimulation()
+	}
+	if !enabled {
+		s.simulation = nil
+	}
+	s.DryRun = enabled
+	s.mu.Unlock()
+
+	if changed {
+		tools.RemoveDryRunWorkspaces(s.ID)
+	}
+}
@@ -0,0 +1,33 @@
+package chat
+
+import "testing"
+
+func TestRunKeepsDryRunMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		started bool
+	}{
+		{name: "dry run switched off", started: true},
+		{name: "live run switched to dry run", started: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &ChatSession{ID: "session", Owner: "alice"}
+			session.SetDryRun(tt.started)
+
+			run := session.RunContext("run")
+			session.SetDryRun(!tt.started)
+
+			if run.DryRun != tt.started {
+				t.Fatalf("run's dry-run mode changed to %v", run.DryRun)
+			}
+			if (run.Simulation != nil) != tt.started {
+				t.Fatalf("run has simulation %v, want %v", run.Simulation != nil, tt.started)
+			}
+			if next := session.RunContext("next"); next.DryRun == tt.started {
+				t.Fatal("the next run didn't pick up the switch")
+			}
+		})
+	}
+}
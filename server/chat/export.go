@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gollama/plan"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type Transcript struct {
+	SessionSummary
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+	Branches []BranchSummary                `json:"branches"`
+	Plan     *plan.Plan                     `json:"plan,omitempty"`
+}
+
+// Transcript returns the conversation without the system prompt, including tool calls and results.
+func (s *ChatSession) Transcript() Transcript {
+	var messages []openai.ChatCompletionMessage
+	for _, message := range s.GetMessages() {
+		if message.Role != openai.ChatMessageRoleSystem {
+			messages = append(messages, message)
+		}
+	}
+
+	return Transcript{
+		SessionSummary: s.Summary(),
+		Messages:       messages,
+		Branches:       s.BranchSummaries(),
+		Plan:           s.GetPlan(),
+	}
+}
+
+// Markdown renders the transcript for reading or pasting into an issue.
+func (t Transcript) Markdown() string {
+	var b strings.Builder
+
+	title := t.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Session: `%s`\n", t.ID)
+	fmt.Fprintf(&b, "- Created: %s\n", t.CreatedAt.Format(time.RFC3339))
+	if len(t.Repos) > 0 {
+		fmt.Fprintf(&b, "- Repositories: %s\n", strings.Join(t.Repos, ", "))
+	}
+
+	if t.Plan != nil {
+		fmt.Fprintf(&b, "\n## Plan (%s)\n\n", t.Plan.Status)
+		if t.Plan.Summary != "" {
+			fmt.Fprintf(&b, "%s\n\n", t.Plan.Summary)
+		}
+		for _, step := range t.Plan.Steps {
+			check := " "
+			if step.Status == plan.StepDone {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %d. %s", check, step.Number, step.Description)
+			if step.Status != plan.StepDone && step.Status != plan.StepPending {
+				fmt.Fprintf(&b, " (%s)", step.Status)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	for _, message := range t.Messages {
+		switch message.Role {
+		case openai.ChatMessageRoleUser:
+			fmt.Fprintf(&b, "\n## User\n\n%s\n", message.Content)
+
+		case openai.ChatMessageRoleAssistant:
+			b.WriteString("\n## Assistant\n\n")
+			if message.Content != "" {
+				fmt.Fprintf(&b, "%s\n", message.Content)
+			}
+			for _, toolCall := range message.ToolCalls {
+				fmt.Fprintf(&b, "\n**Tool call** `%s`\n\n```json\n%s\n```\n", toolCall.Function.Name, toolCall.Function.Arguments)
+			}
+
+		case openai.ChatMessageRoleTool:
+			fmt.Fprintf(&b, "\n**Tool result** `%s`\n\n```\n%s\n```\n", message.Name, message.Content)
+		}
+	}
+
+	return b.String()
+}
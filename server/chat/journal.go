@@ -0,0 +1,42 @@
+package chat
+
+import (
+	"errors"
+
+	"gollama/tools"
+)
+
+var ErrNothingToUndo = errors.New("no agent changes left to undo")
+
+// RunContext describes a run on this session to the tools. It's taken once when the run
+// starts, so the run stays a dry run or a live one throughout, even if the mode is
+// switched while it executes.
+func (s *ChatSession) RunContext(runID string) tools.RunContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return tools.RunContext{
+		SessionID:  s.ID,
+		RunID:      runID,
+		User:       s.Owner,
+		DryRun:     s.DryRun,
+		Simulation: s.simulation,
+		Journal:    s.getJournal(),
+		// repair attempts are counted per run
+		Verification: tools.NewVerification(),
+	}
+}
+
+// Journal returns the session's record of side effects on GitHub.
+func (s *ChatSession) Journal() *tools.Journal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getJournal()
+}
+
+// getJournal must be called with s.mu held.
+func (s *ChatSession) getJournal() *tools.Journal {
+	if s.journal == nil {
+		s.journal = tools.NewJournal()
+	}
+	return s.journal
+}
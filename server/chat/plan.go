@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"errors"
+
+	"gollama/plan"
+)
+
+var (
+	ErrNoPlan          = errors.New("session has no plan")
+	ErrPlanNotProposed = errors.New("plan is not awaiting approval")
+)
+
+// SetPlan replaces the session's plan with a newly proposed one.
+func (s *ChatSession) SetPlan(p *plan.Plan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Plan = p
+}
+
+func (s *ChatSession) GetPlan() *plan.Plan {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Plan == nil {
+		return nil
+	}
+	return s.Plan.Clone()
+}
+
+// UpdatePlan applies update to the plan and returns a copy of the result when it changed.
+func (s *ChatSession) UpdatePlan(update func(p *plan.Plan) bool) (*plan.Plan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Plan == nil || !update(s.Plan) {
+		return nil, false
+	}
+	return s.Plan.Clone(), true
+}
+
+// ApprovePlan marks the proposed plan approved, before the run that executes it starts.
+func (s *ChatSession) ApprovePlan() (*plan.Plan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Plan == nil {
+		return nil, ErrNoPlan
+	}
+	if s.Plan.Status != plan.StatusProposed {
+		return nil, ErrPlanNotProposed
+	}
+	s.Plan.Approve()
+	return s.Plan.Clone(), nil
+}
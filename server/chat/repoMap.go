@@ -0,0 +1,27 @@
+package chat
+
+// SetRepoMap keeps the outline of a repository the session works on. It's taken once,
+// when the repository first comes up, and sent along with every turn after.
+func (s *ChatSession) SetRepoMap(repo string, outline string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.RepoMaps == nil {
+		s.RepoMaps = make(map[string]string)
+	}
+	s.RepoMaps[repo] = outline
+}
+
+// GetRepoMaps returns the outlines in the order their repositories were linked.
+func (s *ChatSession) GetRepoMaps() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var outlines []string
+	for _, repo := range s.Repos {
+		if outline, ok := s.RepoMaps[repo]; ok {
+			outlines = append(outlines, outline)
+		}
+	}
+	return outlines
+}
@@ -1,18 +1,70 @@
 package chat
 
 import (
-	"gollama/llm"
+	"encoding/json"
+	"errors"
+	"gollama/plan"
+	"gollama/tools"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+var (
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrSessionForbidden = errors.New("session belongs to another user")
+	ErrInvalidIndex     = errors.New("message index out of range")
+)
+
+const maxTitleLength = 60
+
 type ChatSession struct {
-	ID       string
-	Messages []openai.ChatCompletionMessage
-	LastUsed time.Time
-	mu       sync.RWMutex
+	ID        string
+	Owner     string
+	Title     string
+	Persona   string
+	Repos     []string
+	RepoMaps  map[string]string
+	Messages  []openai.ChatCompletionMessage
+	Branches  []Branch
+	Plan      *plan.Plan
+	DryRun    bool
+	simulation *tools.Simulation
+	journal   *tools.Journal
+	CreatedAt time.Time
+	LastUsed  time.Time
+	mu        sync.RWMutex
+	runMu     sync.Mutex
+}
+
+type SessionSummary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Persona      string    `json:"persona"`
+	DryRun       bool      `json:"dry_run"`
+	Repos        []string  `json:"repos"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUsed     time.Time `json:"last_used"`
+}
+
+// LockRun serializes agent runs on the session so concurrent turns don't interleave their messages.
+func (s *ChatSession) LockRun() func() {
+	s.runMu.Lock()
+	return s.runMu.Unlock
+}
+
+// TryLockRun is LockRun for callers that must not wait for a run in progress.
+func (s *ChatSession) TryLockRun() (func(), bool) {
+	if !s.runMu.TryLock() {
+		return nil, false
+	}
+	return s.runMu.Unlock, true
 }
 
 func (s *ChatSession) AddMessage(message openai.ChatCompletionMessage) {
@@ -20,6 +72,125 @@ func (s *ChatSession) AddMessage(message openai.ChatCompletionMessage) {
 	defer s.mu.Unlock()
 	s.Messages = append(s.Messages, message)
 	s.LastUsed = time.Now()
+
+	// the first user message names the conversation until someone renames it
+	if s.Title == "" && message.Role == openai.ChatMessageRoleUser {
+		s.Title = titleFrom(message.Content)
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		s.linkRepo(toolCall.Function.Arguments)
+	}
+}
+
+// linkRepo records the owner/repo a tool call targeted. Must be called with s.mu held.
+func (s *ChatSession) linkRepo(arguments string) {
+	var target struct {
+		Owner string `json:"owner"`
+		Repo  string `json:"repo"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &target); err != nil || target.Owner == "" || target.Repo == "" {
+		return
+	}
+
+	s.addRepo(target.Owner + "/" + target.Repo)
+}
+
+// LinkRepo records an owner/repo the conversation is about, e.g. one named in a message.
+func (s *ChatSession) LinkRepo(repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addRepo(repo)
+}
+
+// GetRepos returns the repositories linked to the session, in the order they came up.
+func (s *ChatSession) GetRepos() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string{}, s.Repos...)
+}
+
+// OpenedRepos returns the repositories a workspace was opened for in the conversation.
+func (s *ChatSession) OpenedRepos() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var repos []string
+	for _, message := range s.Messages {
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function.Name != "workspace_open" {
+				continue
+			}
+			var target struct {
+				Owner string `json:"owner"`
+				Repo  string `json:"repo"`
+			}
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &target); err != nil || target.Owner == "" || target.Repo == "" {
+				continue
+			}
+			if fullName := target.Owner + "/" + target.Repo; !slices.Contains(repos, fullName) {
+				repos = append(repos, fullName)
+			}
+		}
+	}
+	return repos
+}
+
+// addRepo must be called with s.mu held.
+func (s *ChatSession) addRepo(repo string) {
+	if !slices.Contains(s.Repos, repo) {
+		s.Repos = append(s.Repos, repo)
+	}
+}
+
+func (s *ChatSession) SetTitle(title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Title = title
+}
+
+func (s *ChatSession) SetPersona(persona string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Persona = persona
+}
+
+func (s *ChatSession) GetPersona() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Persona
+}
+
+// SetSystemPrompt replaces the system prompt, which is rendered from the session's
+// persona at the start of every turn.
+func (s *ChatSession) SetSystemPrompt(prompt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Messages[0].Content = prompt
+}
+
+func (s *ChatSession) Summary() SessionSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SessionSummary{
+		ID:           s.ID,
+		Title:        s.Title,
+		Persona:      s.Persona,
+		DryRun:       s.DryRun,
+		Repos:        append([]string{}, s.Repos...),
+		MessageCount: len(s.Messages) - 1, // without the system prompt
+		CreatedAt:    s.CreatedAt,
+		LastUsed:     s.LastUsed,
+	}
+}
+
+func titleFrom(content string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	title = strings.TrimSpace(title)
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength])) + "…"
+	}
+	return title
 }
 
 func (s *ChatSession) GetMessages() []openai.ChatCompletionMessage {
@@ -45,28 +216,136 @@ func NewSessionManager() *SessionManager {
 	return sm
 }
 
-func (sm *SessionManager) GetOrCreateSession(sessionID string) *ChatSession {
+// GetOrCreateSession returns the session, creating it for owner if it doesn't exist.
+// Sessions are bound to the user who created them.
+func (sm *SessionManager) GetOrCreateSession(sessionID string, owner string) (*ChatSession, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	
 	if session, exists := sm.sessions[sessionID]; exists {
+		if session.Owner != owner {
+			return nil, ErrSessionForbidden
+		}
 		session.LastUsed = time.Now()
-		return session
+		return session, nil
 	}
 	
 	session := &ChatSession{
-		ID:       sessionID,
-		Messages: make([]openai.ChatCompletionMessage, 0),
-		LastUsed: time.Now(),
+		ID:        sessionID,
+		Owner:     owner,
+		Messages:  make([]openai.ChatCompletionMessage, 0),
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
 	}
 	
+	// filled in from the session's persona when the first turn starts
 	session.Messages = append(session.Messages, openai.ChatCompletionMessage{
 		Role: openai.ChatMessageRoleSystem,
-		Content: llm.SystemPrompt,
 	})
 	
 	sm.sessions[sessionID] = session
-	return session
+	return session, nil
+}
+
+// GetSession returns an existing session owned by owner.
+func (sm *SessionManager) GetSession(sessionID string, owner string) (*ChatSession, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, exists := sm.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if session.Owner != owner {
+		return nil, ErrSessionForbidden
+	}
+	return session, nil
+}
+
+// ListSessions returns the owner's sessions, most recently used first.
+func (sm *SessionManager) ListSessions(owner string) []SessionSummary {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	summaries := make([]SessionSummary, 0)
+	for _, session := range sm.sessions {
+		if session.Owner == owner {
+			summaries = append(summaries, session.Summary())
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].LastUsed.After(summaries[j].LastUsed)
+	})
+	return summaries
+}
+
+// Count is the number of live sessions across all users.
+func (sm *SessionManager) Count() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.sessions)
+}
+
+func (sm *SessionManager) DeleteSession(sessionID string, owner string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, exists := sm.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.Owner != owner {
+		return ErrSessionForbidden
+	}
+
+	delete(sm.sessions, sessionID)
+	return nil
+}
+
+// ForkSession copies the session into newID, keeping the transcript up to and including
+// message index (the system prompt is not counted). Tool results that answer a kept
+// tool call are kept too, so the fork is always a valid conversation.
+func (sm *SessionManager) ForkSession(sessionID string, owner string, index int, newID string) (*ChatSession, error) {
+	source, err := sm.GetSession(sessionID, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := source.GetMessages()
+	cut := index + 2 // skip the system prompt, keep index itself
+	if index < 0 || cut > len(messages) {
+		return nil, ErrInvalidIndex
+	}
+	for cut < len(messages) && messages[cut].Role == openai.ChatMessageRoleTool {
+		cut++
+	}
+
+	summary := source.Summary()
+	if summary.Title != "" {
+		summary.Title += " (fork)"
+	}
+
+	source.mu.RLock()
+	repoMaps := maps.Clone(source.RepoMaps)
+	source.mu.RUnlock()
+
+	fork := &ChatSession{
+		ID:        newID,
+		Owner:     owner,
+		Title:     summary.Title,
+		Persona:   summary.Persona,
+		Repos:     summary.Repos,
+		RepoMaps:  repoMaps,
+		Messages:  messages[:cut],
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.sessions[newID] = fork
+	return fork, nil
 }
 
 func (sm *SessionManager) cleanupSessions() {
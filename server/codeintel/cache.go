@@ -0,0 +1,99 @@
+package codeintel
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// an index holds every declaration and reference of a module, keep only a few around
+const maxCachedIndexes = 20
+
+// Cache keeps the indexes of checked-out commits, which never change, so only the
+// first question about a commit pays for parsing it.
+type Cache struct {
+	indexes map[string]*cachedIndex
+	mu      sync.Mutex
+}
+
+type cachedIndex struct {
+	index    *Index
+	lastUsed time.Time
+}
+
+func NewCache() *Cache {
+	c := &Cache{indexes: make(map[string]*cachedIndex)}
+
+	go c.cleanupIndexes()
+
+	return c
+}
+
+// Index returns the index of dir, building it on first use.
+func (c *Cache) Index(dir string) (*Index, error) {
+	c.mu.Lock()
+	cached, exists := c.indexes[dir]
+	if exists {
+		cached.lastUsed = time.Now()
+		c.mu.Unlock()
+		return cached.index, nil
+	}
+	c.mu.Unlock()
+
+	index, err := Build(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.indexes[dir] = &cachedIndex{index: index, lastUsed: time.Now()}
+	c.evict()
+	c.mu.Unlock()
+
+	return index, nil
+}
+
+// Forget drops the indexes of dir and of every directory below it, once they're deleted.
+func (c *Cache) Forget(dir string) {
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for cachedDir := range c.indexes {
+		if cachedDir == filepath.Clean(dir) || strings.HasPrefix(cachedDir, prefix) {
+			delete(c.indexes, cachedDir)
+		}
+	}
+}
+
+// evict drops the least recently used indexes beyond maxCachedIndexes. Must be called
+// with c.mu held.
+func (c *Cache) evict() {
+	for len(c.indexes) > maxCachedIndexes {
+		var oldestDir string
+		var oldest time.Time
+		for dir, cached := range c.indexes {
+			if oldestDir == "" || cached.lastUsed.Before(oldest) {
+				oldestDir, oldest = dir, cached.lastUsed
+			}
+		}
+		delete(c.indexes, oldestDir)
+	}
+}
+
+func (c *Cache) cleanupIndexes() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		for dir, cached := range c.indexes {
+			if time.Since(cached.lastUsed) > 2*time.Hour {
+				delete(c.indexes, dir)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
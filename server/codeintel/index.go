@@ -0,0 +1,170 @@
+package codeintel
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bigger files are generated more often than not and aren't worth parsing
+const maxFileBytes = 1024 * 1024
+
+// Package is one Go package in the tree. External test packages are listed separately
+// from the package they test.
+type Package struct {
+	ImportPath string   `json:"import_path"`
+	Name       string   `json:"name"`
+	Dir        string   `json:"dir"`
+	Files      []string `json:"files"`
+}
+
+// Index is the parsed Go source of a directory tree. Paths in it are relative to the
+// root and use forward slashes.
+type Index struct {
+	Root     string
+	Packages []*Package
+	Symbols  []Symbol
+	fset     *token.FileSet
+	files    []*file
+}
+
+type file struct {
+	path    string
+	pkg     *Package
+	ast     *ast.File
+	content []byte
+}
+
+// Build parses every Go file under root, skipping vendor, testdata and hidden
+// directories like the go command does. Files that don't parse are left out.
+func Build(root string) (*Index, error) {
+	index := &Index{Root: root, fset: token.NewFileSet()}
+	modules := make(map[string]string)
+	packages := make(map[string]*Package)
+
+	err := filepath.WalkDir(root, func(full string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, full)
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			name := entry.Name()
+			if rel != "." && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if module := modulePath(filepath.Join(full, "go.mod")); module != "" {
+				modules[rel] = module
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(entry.Name(), ".go") {
+			return nil
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxFileBytes {
+			return nil
+		}
+
+		content, err := os.ReadFile(full)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		parsed, err := parser.ParseFile(index.fset, rel, content, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil
+		}
+
+		dir := path.Dir(rel)
+		name := parsed.Name.Name
+		key := dir + ":" + name
+		pkg, exists := packages[key]
+		if !exists {
+			pkg = &Package{Name: name, Dir: dir, ImportPath: importPath(modules, dir)}
+			if strings.HasSuffix(name, "_test") {
+				pkg.ImportPath += "_test"
+			}
+			packages[key] = pkg
+			index.Packages = append(index.Packages, pkg)
+		}
+		pkg.Files = append(pkg.Files, rel)
+
+		f := &file{path: rel, pkg: pkg, ast: parsed, content: content}
+		index.files = append(index.files, f)
+		index.Symbols = append(index.Symbols, fileSymbols(index.fset, f)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", root, err)
+	}
+
+	sort.Slice(index.Packages, func(i, j int) bool {
+		return index.Packages[i].ImportPath < index.Packages[j].ImportPath
+	})
+	return index, nil
+}
+
+// Package finds a package by import path or directory.
+func (i *Index) Package(name string) []*Package {
+	name = strings.Trim(name, "/")
+	var found []*Package
+	for _, pkg := range i.Packages {
+		if pkg.ImportPath == name || pkg.Dir == name || strings.TrimSuffix(pkg.ImportPath, "_test") == name {
+			found = append(found, pkg)
+		}
+	}
+	return found
+}
+
+// line returns one trimmed source line.
+func (f *file) line(number int) string {
+	return strings.TrimSpace(f.lines(number, number))
+}
+
+// lines returns the source from line start to end, inclusive.
+func (f *file) lines(start int, end int) string {
+	all := strings.Split(string(f.content), "\n")
+	if start < 1 || start > len(all) {
+		return ""
+	}
+	end = min(end, len(all))
+	return strings.Join(all[start-1:end], "\n")
+}
+
+// modulePath reads the module path from a go.mod file, empty when there is none.
+func modulePath(goMod string) string {
+	content, err := os.ReadFile(goMod)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	return ""
+}
+
+// importPath joins the nearest enclosing module's path with the directory below it.
+// Directories outside any module get their relative path.
+func importPath(modules map[string]string, dir string) string {
+	for current := dir; ; current = path.Dir(current) {
+		if module, ok := modules[current]; ok {
+			if current == dir {
+				return module
+			}
+			return module + "/" + strings.TrimPrefix(dir, strings.TrimPrefix(current+"/", "./"))
+		}
+		if current == "." || current == "/" {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package codeintel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testTree = map[string]string{
+	"go.mod": "module example.com/shop\n\ngo 1.22\n",
+	"cart.go": `package shop
+
+// Cart holds items.
+type Cart struct {
+	items []string
+}
+
+// NewCart returns an empty cart.
+func NewCart() *Cart {
+	return &Cart{}
+}
+
+// Add puts an item in the cart.
+func (c *Cart) Add(item string) {
+	c.items = append(c.items, item)
+}
+
+// Len counts the items.
+func (c Cart) Len() int {
+	return len(c.items)
+}
+
+const MaxItems = 10
+`,
+	"total.go": `package shop
+
+func Total(c Cart) int {
+	return c.Len() + MaxItems
+}
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Push(item T) { s.items = append(s.items, item) }
+`,
+	"cmd/app/main.go": `package main
+
+import "example.com/shop"
+
+func main() {
+	c := shop.NewCart()
+	c.Add("apple")
+	_ = c.Len() + shop.MaxItems
+}
+`,
+	"alias/alias.go": `package alias
+
+import s "example.com/shop"
+
+var Cart = s.NewCart()
+`,
+	// doesn't parse, the rest of the package is still indexed
+	"broken.go":        "package shop\n\nfunc Broken( {\n\treturn MaxItems\n",
+	"testdata/skip.go": "package skip\n\nfunc NewCart() {}\n",
+}
+
+func buildTestIndex(t *testing.T) *Index {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range testTree {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := Build(root)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return index
+}
+
+func TestBuild(t *testing.T) {
+	index := buildTestIndex(t)
+
+	packages := index.Package("example.com/shop")
+	if len(packages) != 1 {
+		t.Fatalf("found %d packages for example.com/shop", len(packages))
+	}
+	if want := []string{"cart.go", "total.go"}; !slices.Equal(packages[0].Files, want) {
+		t.Fatalf("files %v, want %v", packages[0].Files, want)
+	}
+	if packages := index.Package("cmd/app"); len(packages) != 1 || packages[0].ImportPath != "example.com/shop/cmd/app" {
+		t.Fatalf("cmd/app: %+v", packages)
+	}
+	if packages := index.Package("testdata"); len(packages) != 0 {
+		t.Fatalf("testdata was indexed: %+v", packages)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	index := buildTestIndex(t)
+
+	tests := []struct {
+		query    string
+		want     []string
+		kind     string
+		receiver string
+	}{
+		{query: "NewCart", want: []string{"cart.go:9"}, kind: KindFunc},
+		{query: "shop.NewCart", want: []string{"cart.go:9"}, kind: KindFunc},
+		{query: "example.com/shop.NewCart", want: []string{"cart.go:9"}, kind: KindFunc},
+		{query: "Cart.Add", want: []string{"cart.go:14"}, kind: KindMethod, receiver: "Cart"},
+		{query: "Cart.Len", want: []string{"cart.go:19"}, kind: KindMethod, receiver: "Cart"},
+		{query: "shop.Cart.Len", want: []string{"cart.go:19"}, kind: KindMethod, receiver: "Cart"},
+		{query: "example.com/shop.Cart.Len", want: []string{"cart.go:19"}, kind: KindMethod, receiver: "Cart"},
+		{query: "Stack.Push", want: []string{"total.go:9"}, kind: KindMethod, receiver: "Stack"},
+		{query: "MaxItems", want: []string{"cart.go:23"}, kind: KindConst},
+		{query: "shop.Cart", want: []string{"cart.go:4"}, kind: KindType},
+		{query: "Cart", want: []string{"alias/alias.go:5", "cart.go:4"}},
+		// methods are found by their name alone too
+		{query: "Add", want: []string{"cart.go:14"}, kind: KindMethod, receiver: "Cart"},
+		{query: "Other.Add"},
+		{query: "Broken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			found := index.Lookup(tt.query)
+			var locations []string
+			for _, symbol := range found {
+				locations = append(locations, symbol.Location)
+			}
+			if !slices.Equal(locations, tt.want) {
+				t.Fatalf("got %v, want %v", locations, tt.want)
+			}
+			if tt.kind != "" && (found[0].Kind != tt.kind || found[0].Receiver != tt.receiver) {
+				t.Fatalf("got %s with receiver %q, want %s with receiver %q", found[0].Kind, found[0].Receiver, tt.kind, tt.receiver)
+			}
+		})
+	}
+
+	source, truncated := index.Source(index.Lookup("Cart.Add")[0])
+	if want := "func (c *Cart) Add(item string) {\n\tc.items = append(c.items, item)\n}"; source != want || truncated {
+		t.Fatalf("Source = %q, %v", source, truncated)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	index := buildTestIndex(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// through the import, under its own name and an alias
+		{query: "NewCart", want: []string{"alias/alias.go:5", "cmd/app/main.go:6"}},
+		// inside the package and through the import
+		{query: "MaxItems", want: []string{"cmd/app/main.go:8", "total.go:4"}},
+		// methods on a pointer and on a value receiver
+		{query: "Cart.Add", want: []string{"cmd/app/main.go:7"}},
+		{query: "Cart.Len", want: []string{"cmd/app/main.go:8", "total.go:4"}},
+		// the type inside its package, but not alias.Cart
+		{query: "shop.Cart", want: []string{"cart.go:9", "cart.go:10", "cart.go:14", "cart.go:19", "total.go:3"}},
+		{query: "Total"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			symbols := index.Lookup(tt.query)
+			if len(symbols) == 0 {
+				t.Fatalf("%s not found", tt.query)
+			}
+			refs, truncated := index.References(symbols[0], 100)
+			var locations []string
+			for _, ref := range refs {
+				locations = append(locations, fmt.Sprintf("%s:%d", ref.File, ref.Line))
+			}
+			if !slices.Equal(locations, tt.want) || truncated {
+				t.Fatalf("got %v (truncated %v), want %v", locations, truncated, tt.want)
+			}
+		})
+	}
+
+	refs, truncated := index.References(index.Lookup("MaxItems")[0], 1)
+	if len(refs) != 1 || !truncated {
+		t.Fatalf("limit 1: got %d references, truncated %v", len(refs), truncated)
+	}
+	if refs[0].Text != "_ = c.Len() + shop.MaxItems" {
+		t.Fatalf("reference text %q", refs[0].Text)
+	}
+}
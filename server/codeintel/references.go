@@ -0,0 +1,85 @@
+package codeintel
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// Reference is one use of a symbol.
+type Reference struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+}
+
+// References finds the uses of a symbol without type checking. Package-level names are
+// matched inside their package and through selectors on the package's import, which is
+// exact barring shadowing. Methods are matched by selector name alone, so calls of a
+// same-named method on another type are included.
+func (i *Index) References(symbol Symbol, limit int) ([]Reference, bool) {
+	var refs []Reference
+	for _, f := range i.files {
+		importName := ""
+		if f.pkg != symbol.pkg {
+			importName = f.importName(strings.TrimSuffix(symbol.pkg.ImportPath, "_test"), symbol.pkg.Name)
+		}
+		samePackage := f.pkg == symbol.pkg
+
+		// selectors' names are handled with their selector, not as plain identifiers
+		selected := make(map[*ast.Ident]bool)
+		var found []*ast.Ident
+
+		ast.Inspect(f.ast, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.SelectorExpr:
+				selected[node.Sel] = true
+				if node.Sel.Name != symbol.Name {
+					return true
+				}
+				if symbol.Receiver != "" {
+					found = append(found, node.Sel)
+				} else if x, ok := node.X.(*ast.Ident); ok && importName != "" && x.Name == importName {
+					found = append(found, node.Sel)
+				}
+
+			case *ast.Ident:
+				if samePackage && symbol.Receiver == "" && node.Name == symbol.Name && !selected[node] && node.Pos() != symbol.ident {
+					found = append(found, node)
+				}
+			}
+			return true
+		})
+
+		for _, ident := range found {
+			if len(refs) == limit {
+				return refs, true
+			}
+			position := i.fset.Position(ident.Pos())
+			refs = append(refs, Reference{
+				File:   f.path,
+				Line:   position.Line,
+				Column: position.Column,
+				Text:   f.line(position.Line),
+			})
+		}
+	}
+	return refs, false
+}
+
+// importName is the name a file refers to an import path by, empty when it doesn't
+// import it.
+func (f *file) importName(importPath string, pkgName string) string {
+	for _, spec := range f.ast.Imports {
+		imported, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || imported != importPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return pkgName
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package codeintel
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+const (
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindType   = "type"
+	KindConst  = "const"
+	KindVar    = "var"
+)
+
+// definitions longer than this are cut in Definition results
+const maxDefinitionLines = 80
+
+// Symbol is a top-level declaration. Methods carry their receiver's type name.
+type Symbol struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Receiver string `json:"receiver,omitempty"`
+	Package  string `json:"package"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	EndLine  int    `json:"end_line"`
+	// Location is the file:line form the model reads files with
+	Location  string `json:"location"`
+	Exported  bool   `json:"exported"`
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty"`
+	pkg       *Package
+	ident     token.Pos
+}
+
+// FileSymbols lists the symbols of a package, grouped by file.
+func (i *Index) FileSymbols(pkg *Package, exportedOnly bool) map[string][]Symbol {
+	files := make(map[string][]Symbol)
+	for _, symbol := range i.Symbols {
+		if symbol.pkg != pkg || (exportedOnly && !symbol.Exported) {
+			continue
+		}
+		files[symbol.File] = append(files[symbol.File], symbol)
+	}
+	return files
+}
+
+// Lookup finds the symbols a name refers to: Name, pkg.Name, Type.Method or
+// pkg.Type.Method, where pkg is a package name or import path.
+func (i *Index) Lookup(query string) []Symbol {
+	qualifier, name := "", query
+	if dot := strings.LastIndex(query, "."); dot >= 0 {
+		qualifier, name = query[:dot], query[dot+1:]
+	}
+
+	var found []Symbol
+	for _, symbol := range i.Symbols {
+		if symbol.Name != name {
+			continue
+		}
+		if qualifier == "" || matchesQualifier(symbol, qualifier) {
+			found = append(found, symbol)
+		}
+	}
+
+	sort.SliceStable(found, func(a, b int) bool {
+		// exported declarations outside tests are what's usually meant
+		if rankSymbol(found[a]) != rankSymbol(found[b]) {
+			return rankSymbol(found[a]) < rankSymbol(found[b])
+		}
+		return found[a].Location < found[b].Location
+	})
+	return found
+}
+
+// Source returns a symbol's declaration, cut after maxDefinitionLines.
+func (i *Index) Source(symbol Symbol) (string, bool) {
+	for _, f := range i.files {
+		if f.path != symbol.File {
+			continue
+		}
+		end := symbol.EndLine
+		truncated := end-symbol.Line+1 > maxDefinitionLines
+		if truncated {
+			end = symbol.Line + maxDefinitionLines - 1
+		}
+		return f.lines(symbol.Line, end), truncated
+	}
+	return "", false
+}
+
+func matchesQualifier(symbol Symbol, qualifier string) bool {
+	if symbol.Receiver == qualifier {
+		return true
+	}
+
+	// import paths have dots of their own, like example.com/shop
+	pkgName := qualifier
+	if dot := strings.LastIndex(qualifier, "."); dot >= 0 && !strings.Contains(qualifier[dot+1:], "/") {
+		if symbol.Receiver != qualifier[dot+1:] {
+			return false
+		}
+		pkgName = qualifier[:dot]
+	} else if symbol.Receiver != "" {
+		return false
+	}
+
+	return symbol.pkg.Name == pkgName || symbol.pkg.ImportPath == pkgName || lastElem(symbol.pkg.ImportPath) == pkgName
+}
+
+func rankSymbol(symbol Symbol) int {
+	rank := 0
+	if !symbol.Exported {
+		rank++
+	}
+	if strings.HasSuffix(symbol.File, "_test.go") {
+		rank += 2
+	}
+	return rank
+}
+
+func fileSymbols(fset *token.FileSet, f *file) []Symbol {
+	var symbols []Symbol
+	add := func(name *ast.Ident, kind string, receiver string, node ast.Node, signature string, doc *ast.CommentGroup) {
+		if name.Name == "_" {
+			return
+		}
+		line := fset.Position(node.Pos()).Line
+		symbols = append(symbols, Symbol{
+			Name:      name.Name,
+			Kind:      kind,
+			Receiver:  receiver,
+			Package:   f.pkg.ImportPath,
+			File:      f.path,
+			Line:      line,
+			EndLine:   fset.Position(node.End()).Line,
+			Location:  fmt.Sprintf("%s:%d", f.path, line),
+			Exported:  name.IsExported(),
+			Signature: signature,
+			Doc:       firstLine(doc),
+			pkg:       f.pkg,
+			ident:     name.Pos(),
+		})
+	}
+
+	for _, decl := range f.ast.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			kind, receiver := KindFunc, ""
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				kind, receiver = KindMethod, receiverName(decl.Recv.List[0].Type)
+			}
+			add(decl.Name, kind, receiver, decl, render(fset, &ast.FuncDecl{Recv: decl.Recv, Name: decl.Name, Type: decl.Type}), decl.Doc)
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				doc := decl.Doc
+				node := ast.Node(spec)
+				if len(decl.Specs) == 1 {
+					node = decl
+				}
+
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+					add(spec.Name, KindType, "", node, "type "+firstRenderedLine(fset, spec), doc)
+
+				case *ast.ValueSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+					kind := KindVar
+					if decl.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, name := range spec.Names {
+						add(name, kind, "", node, kind+" "+firstRenderedLine(fset, spec), doc)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// receiverName strips pointers and type parameters from a method receiver.
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+func render(fset *token.FileSet, node any) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, node); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// firstRenderedLine keeps declarations like structs to their opening line.
+func firstRenderedLine(fset *token.FileSet, node any) string {
+	rendered := render(fset, node)
+	if first, _, multiline := strings.Cut(rendered, "\n"); multiline {
+		return first + " ... }"
+	}
+	return rendered
+}
+
+func firstLine(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(doc.Text()), "\n")
+	return first
+}
+
+func lastElem(importPath string) string {
+	return importPath[strings.LastIndex(importPath, "/")+1:]
+}
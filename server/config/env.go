@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -10,7 +12,39 @@ import (
 type Config struct {
 	Port string
 	BaseURL string
+	AgentMaxIterations int
 	GithubToken string
+	GithubWebhookSecret string
+	WebhookRulesFile string
+	WebhookAuthorAssociations []string
+	PromptsDir string
+	JobsDir string
+	JobWorkers int
+	JobMaxAttempts int
+	WorkspacesDir string
+	GitRemoteURL string
+	GitAuthorName string
+	GitAuthorEmail string
+	CommandAllowlist []string
+	CommandTimeout int
+	CommandMemoryMB int
+	CommandNetwork bool
+	VerifyChecks []string
+	VerifyMaxRepairs int
+	RepoMapAuto bool
+	TrustedRepos []string
+	EmbeddingModel string
+	IndexDir string
+	AllowedOrigins []string
+	AuthTokens map[string]string
+	AuthCookieSecret string
+	AuthDisabled bool
+	LogFormat string
+	LogLevel string
+	TracingEndpoint string
+	TracingServiceName string
+	AuditLog string
+	AuditAdmins []string
 }
 
 var ENV *Config
@@ -39,15 +73,218 @@ func Load() (*Config, error) {
 		baseURL = "http://localhost:11434/v1"
 	}
 	
+	// model calls with tools per run, a workspace flow with checks and repairs needs many
+	agentMaxIterations := intEnv("AGENT_MAX_ITERATIONS", 25)
+
 	githubToken := os.Getenv("GITHUB_TOKEN")
 	if githubToken == "" {
 		log.Println("No GITHUB_TOKEN environment variable found")
 	}
 	
+	githubWebhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	if githubWebhookSecret == "" {
+		log.Println("No GITHUB_WEBHOOK_SECRET environment variable found, GitHub webhooks will be rejected")
+	}
+
+	// optional, the built-in webhook rules are used when empty
+	webhookRulesFile := os.Getenv("WEBHOOK_RULES_FILE")
+
+	// comment and review authors who can trigger the agent, label events need write access instead
+	webhookAuthorAssociations := listEnv("WEBHOOK_AUTHOR_ASSOCIATIONS")
+	if len(webhookAuthorAssociations) == 0 {
+		webhookAuthorAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+	}
+
+	// optional, only the built-in personas are available when empty
+	promptsDir := os.Getenv("PROMPTS_DIR")
+
+	jobsDir := os.Getenv("JOBS_DIR")
+	if jobsDir == "" {
+		log.Println("No JOBS_DIR environment variable found, using default directory data/jobs")
+		jobsDir = "data/jobs"
+	}
+
+	jobWorkers := intEnv("JOB_WORKERS", 4)
+	jobMaxAttempts := intEnv("JOB_MAX_ATTEMPTS", 3)
+
+	workspacesDir := os.Getenv("WORKSPACES_DIR")
+	if workspacesDir == "" {
+		log.Println("No WORKSPACES_DIR environment variable found, using default directory data/workspaces")
+		workspacesDir = "data/workspaces"
+	}
+
+	// {owner} and {repo} are filled in, so any git remote works, including local bare repos
+	gitRemoteURL := os.Getenv("GIT_REMOTE_URL")
+	if gitRemoteURL == "" {
+		gitRemoteURL = "https://github.com/{owner}/{repo}.git"
+	}
+
+	gitAuthorName := os.Getenv("GIT_AUTHOR_NAME")
+	if gitAuthorName == "" {
+		gitAuthorName = "Gollama"
+	}
+
+	gitAuthorEmail := os.Getenv("GIT_AUTHOR_EMAIL")
+	if gitAuthorEmail == "" {
+		gitAuthorEmail = "gollama@users.noreply.github.com"
+	}
+
+	// commands run_command accepts: the words they start with, then the only flags they may pass
+	commandAllowlist := listEnv("COMMAND_ALLOWLIST")
+	if len(commandAllowlist) == 0 {
+		commandAllowlist = []string{"go build -v", "go test -v -run -count -short -race -timeout", "go vet", "npm test", "npm run lint", "make test", "make lint", "cargo test", "pytest -v -q -x -k"}
+	}
+
+	commandTimeout := intEnv("COMMAND_TIMEOUT", 300)
+	commandMemoryMB := intEnv("COMMAND_MEMORY_MB", 2048)
+
+	// commands run without network unless this is set
+	commandNetwork := os.Getenv("COMMAND_NETWORK") == "true"
+
+	// checks for repositories that don't declare their own in .gollama.json
+	verifyChecks := listEnv("VERIFY_CHECKS")
+	verifyMaxRepairs := intEnv("VERIFY_MAX_REPAIRS", 3)
+
+	// outline a repository in the background when a session first mentions it, which
+	// clones it; the repo_map tool works either way
+	repoMapAuto := os.Getenv("REPO_MAP_AUTO") == "true"
+
+	// owners or owner/repo names whose conventions files are passed to the agent, on top
+	// of the repositories a session opened a workspace for; * trusts every repository
+	trustedRepos := listEnv("TRUSTED_REPOS")
+
+	// served by the same OpenAI-compatible API as the chat models
+	embeddingModel := os.Getenv("EMBEDDING_MODEL")
+	if embeddingModel == "" {
+		log.Println("No EMBEDDING_MODEL environment variable found, using default model nomic-embed-text")
+		embeddingModel = "nomic-embed-text"
+	}
+
+	indexDir := os.Getenv("INDEX_DIR")
+	if indexDir == "" {
+		log.Println("No INDEX_DIR environment variable found, using default directory data/index")
+		indexDir = "data/index"
+	}
+
+	allowedOrigins := listEnv("ALLOWED_ORIGINS")
+	if len(allowedOrigins) == 0 {
+		log.Println("No ALLOWED_ORIGINS environment variable found, using default origin http://localhost:3000")
+		allowedOrigins = []string{"http://localhost:3000"}
+	}
+
+	// comma separated token:user pairs
+	authTokens := make(map[string]string)
+	for _, pair := range listEnv("AUTH_TOKENS") {
+		token, user, ok := strings.Cut(pair, ":")
+		if !ok || token == "" || user == "" {
+			log.Printf("Ignoring malformed AUTH_TOKENS entry, expected token:user")
+			continue
+		}
+		authTokens[token] = user
+	}
+
+	authCookieSecret := os.Getenv("AUTH_COOKIE_SECRET")
+
+	// only takes effect without tokens or a cookie secret, every request is then the
+	// same anonymous user
+	authDisabled := os.Getenv("AUTH_DISABLED") == "true"
+	if len(authTokens) == 0 && authCookieSecret == "" {
+		if authDisabled {
+			log.Println("No AUTH_TOKENS or AUTH_COOKIE_SECRET environment variable found and AUTH_DISABLED is set, authentication is disabled")
+		} else {
+			log.Println("No AUTH_TOKENS or AUTH_COOKIE_SECRET environment variable found, the server won't start without them or AUTH_DISABLED=true")
+		}
+	}
+
+	// text for reading in a terminal, json for log collectors
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "text"
+	}
+
+	// full tool arguments and results are only logged at debug
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+
+	// an OTLP/HTTP collector like http://localhost:4318, spans are dropped when empty
+	tracingEndpoint := os.Getenv("TRACING_ENDPOINT")
+
+	tracingServiceName := os.Getenv("TRACING_SERVICE_NAME")
+	if tracingServiceName == "" {
+		tracingServiceName = "gollama"
+	}
+
+	auditLog := os.Getenv("AUDIT_LOG")
+	if auditLog == "" {
+		log.Println("No AUDIT_LOG environment variable found, using default file data/audit/audit.jsonl")
+		auditLog = "data/audit/audit.jsonl"
+	}
+
+	// users who can read everyone's audit entries, the rest only see their own
+	auditAdmins := listEnv("AUDIT_ADMINS")
+
 	return &Config{
 		Port: port,
 		BaseURL: baseURL,
+		AgentMaxIterations: agentMaxIterations,
 		GithubToken: githubToken,
+		GithubWebhookSecret: githubWebhookSecret,
+		WebhookRulesFile: webhookRulesFile,
+		WebhookAuthorAssociations: webhookAuthorAssociations,
+		PromptsDir: promptsDir,
+		JobsDir: jobsDir,
+		JobWorkers: jobWorkers,
+		JobMaxAttempts: jobMaxAttempts,
+		WorkspacesDir: workspacesDir,
+		GitRemoteURL: gitRemoteURL,
+		GitAuthorName: gitAuthorName,
+		GitAuthorEmail: gitAuthorEmail,
+		CommandAllowlist: commandAllowlist,
+		CommandTimeout: commandTimeout,
+		CommandMemoryMB: commandMemoryMB,
+		CommandNetwork: commandNetwork,
+		VerifyChecks: verifyChecks,
+		VerifyMaxRepairs: verifyMaxRepairs,
+		RepoMapAuto: repoMapAuto,
+		TrustedRepos: trustedRepos,
+		EmbeddingModel: embeddingModel,
+		IndexDir: indexDir,
+		AllowedOrigins: allowedOrigins,
+		AuthTokens: authTokens,
+		AuthCookieSecret: authCookieSecret,
+		AuthDisabled: authDisabled,
+		LogFormat: logFormat,
+		LogLevel: logLevel,
+		TracingEndpoint: tracingEndpoint,
+		TracingServiceName: tracingServiceName,
+		AuditLog: auditLog,
+		AuditAdmins: auditAdmins,
 	}, nil
 }
 
+func listEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func intEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		log.Printf("Invalid %s value %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
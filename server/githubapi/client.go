@@ -0,0 +1,52 @@
+package githubapi
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"gollama/config"
+	"gollama/metrics"
+	"gollama/tracing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+var (
+	client     *github.Client
+	clientOnce sync.Once
+)
+
+// Client is the GitHub API client every caller shares. It authenticates with the
+// configured token and records each request and the remaining rate limit.
+func Client() *github.Client {
+	clientOnce.Do(func() {
+		httpClient := &http.Client{Transport: &instrumentedTransport{next: tracing.Transport(http.DefaultTransport)}}
+		client = github.NewClient(httpClient).WithAuthToken(config.ENV.GithubToken)
+	})
+	return client
+}
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		metrics.GithubRequests.WithLabelValues(req.Method, "0").Inc()
+		return nil, err
+	}
+
+	metrics.GithubRequests.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+	// search, graphql and the rest each have their own window
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		resource := resp.Header.Get("X-RateLimit-Resource")
+		if resource == "" {
+			resource = "core"
+		}
+		metrics.GithubRateLimitRemaining.WithLabelValues(resource).Set(float64(remaining))
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+const (
+	SourceChat    = "chat"
+	SourceWebhook = "webhook"
+)
+
+const (
+	EventStatus = "status"
+	EventResult = "result"
+	EventError  = "error"
+)
+
+type Event struct {
+	Seq     int       `json:"seq"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+type Job struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	SessionID   string          `json:"session_id"`
+	User        string          `json:"user"`
+	Prompt      string          `json:"prompt"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	Result      string          `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Events      []Event         `json:"events,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	NextAttempt time.Time       `json:"next_attempt,omitempty"`
+	// the trace of the request that queued the job, continued when it runs
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	c.Events = make([]Event, len(j.Events))
+	copy(c.Events, j.Events)
+	return &c
+}
@@ -0,0 +1,351 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gollama/tracing"
+)
+
+var (
+	ErrQueueFull    = errors.New("job queue is full")
+	ErrJobNotFound  = errors.New("job not found")
+	ErrNotResumable = errors.New("job can't resume after a restart")
+)
+
+// Runner executes one attempt of a job. emit publishes progress events to anyone attached to the job.
+type Runner func(ctx context.Context, job *Job, emit func(eventType, message string)) (string, error)
+
+type Queue struct {
+	store       *Store
+	runner      Runner
+	workers     int
+	maxAttempts int
+	timeout     time.Duration
+	pending     chan string
+	jobs        map[string]*Job
+	subscribers map[string][]chan Event
+	mu          sync.Mutex
+}
+
+func NewQueue(store *Store, workers int, maxAttempts int, runner Runner) *Queue {
+	return &Queue{
+		store:       store,
+		runner:      runner,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		timeout:     30 * time.Minute,
+		pending:     make(chan string, 1024),
+		jobs:        make(map[string]*Job),
+		subscribers: make(map[string][]chan Event),
+	}
+}
+
+// Start reloads persisted jobs, requeues the ones that never finished and starts the
+// workers. Unfinished jobs resumable turns down, like a chat turn whose session didn't
+// survive the restart, fail instead of running without their context.
+func (q *Queue) Start(resumable func(*Job) bool) error {
+	persisted, err := q.store.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	for _, job := range persisted {
+		q.jobs[job.ID] = job
+		if job.Done() {
+			continue
+		}
+
+		if !resumable(job) {
+			job.Status = StatusFailed
+			job.Error = ErrNotResumable.Error()
+			job.UpdatedAt = time.Now()
+			q.appendEvent(job, EventError, "Server restarted and the conversation is gone, send the message again")
+			q.save(job)
+			continue
+		}
+
+		// a job that was running when the server stopped gets another attempt
+		job.Status = StatusQueued
+		q.appendEvent(job, EventStatus, "Server restarted, resuming job")
+		q.save(job)
+		q.schedule(job.ID, time.Until(job.NextAttempt))
+	}
+	q.mu.Unlock()
+
+	for range q.workers {
+		go q.work()
+	}
+
+	go q.cleanupJobs()
+
+	return nil
+}
+
+func (q *Queue) Enqueue(ctx context.Context, source string, sessionID string, user string, prompt string, payload any) (*Job, error) {
+	var raw json.RawMessage
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+		}
+		raw = data
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:        generateJobID(),
+		Source:    source,
+		SessionID: sessionID,
+		User:      user,
+		Prompt:    prompt,
+		Payload:   raw,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+
+		TraceContext: tracing.Inject(ctx),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.store.Save(job); err != nil {
+		return nil, err
+	}
+	q.jobs[job.ID] = job
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		job.Status = StatusFailed
+		job.Error = ErrQueueFull.Error()
+		q.save(job)
+		return nil, ErrQueueFull
+	}
+
+	return job.clone(), nil
+}
+
+func (q *Queue) Get(jobID string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, exists := q.jobs[jobID]
+	if !exists {
+		return nil, false
+	}
+	return job.clone(), true
+}
+
+// Busy reports whether the session has a job that is queued, waiting for a retry or
+// running.
+func (q *Queue) Busy(sessionID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, job := range q.jobs {
+		if job.SessionID == sessionID && !job.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns the events after afterSeq and a channel of live events.
+// The channel is closed once the job finishes or the subscriber falls too far behind.
+func (q *Queue) Subscribe(jobID string, afterSeq int) ([]Event, <-chan Event, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, exists := q.jobs[jobID]
+	if !exists {
+		return nil, nil, nil, ErrJobNotFound
+	}
+
+	var missed []Event
+	for _, event := range job.Events {
+		if event.Seq > afterSeq {
+			missed = append(missed, event)
+		}
+	}
+
+	ch := make(chan Event, 64)
+	if job.Done() {
+		close(ch)
+		return missed, ch, func() {}, nil
+	}
+
+	q.subscribers[jobID] = append(q.subscribers[jobID], ch)
+
+	cancel := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.removeSubscriber(jobID, ch)
+	}
+
+	return missed, ch, cancel, nil
+}
+
+func (q *Queue) work() {
+	for jobID := range q.pending {
+		q.run(jobID)
+	}
+}
+
+func (q *Queue) run(jobID string) {
+	q.mu.Lock()
+	job, exists := q.jobs[jobID]
+	if !exists || job.Done() {
+		q.mu.Unlock()
+		return
+	}
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	q.save(job)
+	snapshot := job.clone()
+	q.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(tracing.Extract(context.Background(), snapshot.TraceContext), q.timeout)
+	result, err := q.runner(ctx, snapshot, func(eventType, message string) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.appendEvent(job, eventType, message)
+		q.save(job)
+	})
+	cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job.UpdatedAt = time.Now()
+
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Result = result
+		job.Error = ""
+		q.appendEvent(job, EventResult, result)
+		q.closeSubscribers(jobID)
+
+	case IsTransient(err) && job.Attempts < q.maxAttempts:
+		delay := backoff(job.Attempts)
+		slog.Warn("Job attempt failed, retrying", "job_id", jobID, "session_id", job.SessionID, "attempt", job.Attempts, "delay", delay, "error", err)
+		job.Status = StatusQueued
+		job.Error = err.Error()
+		job.NextAttempt = time.Now().Add(delay)
+		q.appendEvent(job, EventStatus, fmt.Sprintf("Hit a temporary problem, retrying in %s…", delay))
+		q.schedule(jobID, delay)
+
+	default:
+		slog.Error("Job failed", "job_id", jobID, "session_id", job.SessionID, "attempts", job.Attempts, "error", err)
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		q.appendEvent(job, EventError, err.Error())
+		q.closeSubscribers(jobID)
+	}
+
+	q.save(job)
+}
+
+// schedule hands a queued job to the workers after delay. Unlike new jobs, which are
+// refused when the queue is full, it waits for room, so retries and resumed jobs always
+// run eventually.
+func (q *Queue) schedule(jobID string, delay time.Duration) {
+	push := func() {
+		q.pending <- jobID
+	}
+
+	if delay <= 0 {
+		go push()
+		return
+	}
+	time.AfterFunc(delay, push)
+}
+
+// appendEvent must be called with q.mu held.
+func (q *Queue) appendEvent(job *Job, eventType string, message string) {
+	event := Event{
+		Seq:     len(job.Events) + 1,
+		Type:    eventType,
+		Message: message,
+		Time:    time.Now(),
+	}
+	job.Events = append(job.Events, event)
+
+	var slow []chan Event
+	for _, ch := range q.subscribers[job.ID] {
+		select {
+		case ch <- event:
+		default:
+			slow = append(slow, ch)
+		}
+	}
+
+	// a dropped subscriber can re-attach with its last seq and catch up from the stored events
+	for _, ch := range slow {
+		q.removeSubscriber(job.ID, ch)
+	}
+}
+
+// removeSubscriber must be called with q.mu held.
+func (q *Queue) removeSubscriber(jobID string, ch chan Event) {
+	subscribers := q.subscribers[jobID]
+	for i, existing := range subscribers {
+		if existing == ch {
+			q.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// closeSubscribers must be called with q.mu held.
+func (q *Queue) closeSubscribers(jobID string) {
+	for _, ch := range q.subscribers[jobID] {
+		close(ch)
+	}
+	delete(q.subscribers, jobID)
+}
+
+// save must be called with q.mu held.
+func (q *Queue) save(job *Job) {
+	if err := q.store.Save(job); err != nil {
+		slog.Error("Failed to persist job", "job_id", job.ID, "error", err)
+	}
+}
+
+func (q *Queue) cleanupJobs() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		q.mu.Lock()
+		now := time.Now()
+		for id, job := range q.jobs {
+			// finished jobs are kept around for a day so clients can still fetch results
+			if job.Done() && now.Sub(job.UpdatedAt) > 24*time.Hour {
+				delete(q.jobs, id)
+				if err := q.store.Delete(id); err != nil {
+					slog.Error("Failed to delete job", "job_id", id, "error", err)
+				}
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+func generateJobID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
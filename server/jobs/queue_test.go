@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartResumesOnlyResumableJobs(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// left over from before a restart
+	for _, job := range []*Job{
+		{ID: "chat", Source: SourceChat, SessionID: "gone", Status: StatusRunning},
+		{ID: "webhook", Source: SourceWebhook, SessionID: "github:acme/demo#1", Status: StatusQueued},
+		{ID: "done", Source: SourceChat, SessionID: "gone", Status: StatusSucceeded},
+	} {
+		if err := store.Save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ran := make(chan string, 3)
+	q := NewQueue(store, 1, 1, func(ctx context.Context, job *Job, emit func(string, string)) (string, error) {
+		ran <- job.ID
+		return "ok", nil
+	})
+	if err := q.Start(func(job *Job) bool { return job.Source == SourceWebhook }); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case id := <-ran:
+		if id != "webhook" {
+			t.Fatalf("ran %s, want webhook", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumable job never ran")
+	}
+
+	chat, _ := q.Get("chat")
+	if chat.Status != StatusFailed || chat.Error != ErrNotResumable.Error() {
+		t.Fatalf("chat job: status %s, error %q", chat.Status, chat.Error)
+	}
+	if done, _ := q.Get("done"); done.Status != StatusSucceeded {
+		t.Fatalf("finished job: status %s", done.Status)
+	}
+	select {
+	case id := <-ran:
+		t.Fatalf("ran %s, which can't resume", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBusy(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan string)
+	release := make(chan struct{})
+	q := NewQueue(store, 1, 1, func(ctx context.Context, job *Job, emit func(string, string)) (string, error) {
+		started <- job.ID
+		<-release
+		return "ok", nil
+	})
+	if err := q.Start(func(*Job) bool { return true }); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if q.Busy("a") {
+		t.Fatal("busy without jobs")
+	}
+
+	running, err := q.Enqueue(context.Background(), SourceChat, "a", "alice", "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// waits behind the first one on the single worker
+	if _, err := q.Enqueue(context.Background(), SourceChat, "b", "alice", "second", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !q.Busy("a") {
+		t.Fatal("session with a running job isn't busy")
+	}
+	if !q.Busy("b") {
+		t.Fatal("session with a queued job isn't busy")
+	}
+	if q.Busy("c") {
+		t.Fatal("session without jobs is busy")
+	}
+
+	release <- struct{}{}
+	<-started
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Busy("a") || q.Busy("b") {
+		if time.Now().After(deadline) {
+			job, _ := q.Get(running.ID)
+			t.Fatalf("still busy after the jobs finished, first job %s", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestIsTransient(t *testing.T) {
+	githubError := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "wrapped canceled", err: fmt.Errorf("run: %w", context.Canceled), want: false},
+		{name: "plain error", err: errors.New("unknown tool"), want: false},
+
+		{name: "llm rate limit", err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "llm overloaded", err: fmt.Errorf("chat completion failed: %w", &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable}), want: true},
+		{name: "llm bad request", err: &openai.APIError{HTTPStatusCode: http.StatusBadRequest}, want: false},
+		{name: "llm request 502", err: &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, want: true},
+		{name: "llm request 404", err: &openai.RequestError{HTTPStatusCode: http.StatusNotFound}, want: false},
+
+		{name: "github rate limit", err: &github.RateLimitError{}, want: true},
+		{name: "github secondary rate limit", err: &github.AbuseRateLimitError{}, want: true},
+		{name: "github 500", err: githubError(http.StatusInternalServerError), want: true},
+		{name: "github 422", err: githubError(http.StatusUnprocessableEntity), want: false},
+		{name: "github without response", err: &github.ErrorResponse{}, want: false},
+
+		{name: "timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 5 * time.Second},
+		{attempt: 2, want: 10 * time.Second},
+		{attempt: 4, want: 40 * time.Second},
+		{attempt: 20, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
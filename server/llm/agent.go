@@ -4,62 +4,64 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"gollama/logging"
+	"gollama/metrics"
 	"gollama/tools"
+	"gollama/tracing"
 
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Agent struct {
-	client *openai.Client
-	model  string
+	client        *openai.Client
+	model         string
+	maxIterations int
 }
 
 const (
-	SystemPrompt = `
-		You are Gollama, an expert AI Software Engineer Buddy.
-		You always communicate in casual human tone.
-		
-		IMPORTANT WORKFLOW:
-		When a user asks you to make changes to a repository (create PR, fix issues, implement features):
-		
-		PHASE 1 - PLANNING:
-		1. First, create a detailed plan of what you will do
-		2. List all the steps you'll take with specific tool calls
-		3. Ask for user approval before proceeding
-		4. Wait for explicit approval
-		
-		PHASE 2 - EXECUTION (USE TOOLS):
-		5. Only after approval, start using tools to execute the plan
-		6. Use tools in the planned sequence
-		7. Once you complete all steps, provide a brief summary of the changes made
-		
-		For general questions, repository exploration, or single tool calls, you can use tools directly.
-		
-		When creating implementation plans, be specific about:
-		- Which files you'll examine
-		- What branch name you'll use  
-		- What code changes you'll make
-		- Commit messages you'll use
-		- PR title and description
-	`
+	// the model asked for one or more tool calls
+	EventToolCalls = "tool_calls"
+	// a tool call finished; Err is set when it failed
+	EventToolResult = "tool_result"
 )
 
-func (a *Agent) RunSessionConversation(ctx context.Context, messages []openai.ChatCompletionMessage, statusCallback func(string)) ([]openai.ChatCompletionMessage, error) {
+// Event reports the agent's progress through a conversation to the caller.
+type Event struct {
+	Type       string
+	Tool       string
+	ToolCallID string
+	Arguments  string
+	Result     string
+	Mutating   bool
+	Err        error
+}
+
+// RunSessionConversation runs the tool loop over messages and returns them with
+// everything the model and the tools added. When it fails it still returns the messages
+// up to the last completed step, so the caller can keep the work already done.
+func (a *Agent) RunSessionConversation(ctx context.Context, messages []openai.ChatCompletionMessage, onEvent func(Event)) (_ []openai.ChatCompletionMessage, err error) {
+	ctx, span := tracing.Start(ctx, "agent.run", trace.WithAttributes(attribute.String("gen_ai.request.model", a.model)))
+	defer func() { tracing.End(span, err) }()
+
 	availableTools := tools.GetAvailableTools()
 	var toolDefs []openai.Tool
 	for _, t := range availableTools {
 		toolDefs = append(toolDefs, t.Definition)
 	}
 
-	const maxIterations = 6
-	for step := range maxIterations {
-		log.Printf("========== Agent Call %d ==========", step+1)
-		log.Println("Step 1: Sending conversation history and tool definitions to LLM...")
+	iterations := 0
+	for step := range a.maxIterations {
+		iterations = step + 1
+		iterationCtx := logging.With(ctx, "iteration", step+1)
+		slog.InfoContext(iterationCtx, "Sending conversation to model", "model", a.model, "messages", len(messages), "tools", len(toolDefs))
 
-		resp, err := a.client.CreateChatCompletion(
-			ctx,
+		resp, err := a.createChatCompletion(
+			iterationCtx,
 			openai.ChatCompletionRequest{
 				Model:    a.model,
 				Messages: messages,
@@ -67,44 +69,69 @@ func (a *Agent) RunSessionConversation(ctx context.Context, messages []openai.Ch
 			},
 		)
 		if err != nil {
-			return nil, fmt.Errorf("chat completion failed: %w", err)
+			return messages, fmt.Errorf("chat completion failed: %w", err)
 		}
 		if len(resp.Choices) == 0 {
-			return nil, errors.New("no response choices from LLM")
+			return messages, errors.New("no response choices from LLM")
 		}
 
 		responseMessage := resp.Choices[0].Message
 		messages = append(messages, responseMessage)
 
 		if len(responseMessage.ToolCalls) == 0 {
-			log.Println("No tool calls requested. Agent finished.")
+			slog.InfoContext(iterationCtx, "Model answered without tool calls, agent finished")
 			break
 		}
 		
-		if statusCallback != nil {
-			statusCallback("tools_used")
+		if onEvent != nil {
+			onEvent(Event{Type: EventToolCalls})
 		}
 
-		log.Printf("Step 2: LLM requested %d tool call(s)", len(responseMessage.ToolCalls))
+		slog.InfoContext(iterationCtx, "Model requested tool calls", "tool_calls", len(responseMessage.ToolCalls))
 
 		var toolResponses []openai.ChatCompletionMessage
 
 		for _, toolCall := range responseMessage.ToolCalls {
 			functionName := toolCall.Function.Name
-			log.Printf("Tool call requested: %s", functionName)
+			toolCtx := logging.With(iterationCtx, "tool", functionName, "tool_call_id", toolCall.ID)
 
 			tool, ok := availableTools[functionName]
 			if !ok {
-				return nil, fmt.Errorf("LLM requested an unknown tool: %s", functionName)
+				// the tool calls would go unanswered, so the step is dropped
+				return messages[:len(messages)-1], fmt.Errorf("LLM requested an unknown tool: %s", functionName)
 			}
 
-			log.Printf("Executing tool '%s' with args: %s", functionName, toolCall.Function.Arguments)
-			toolResult, err := tool.Execute(ctx, toolCall.Function.Arguments)
+			slog.DebugContext(toolCtx, "Executing tool", "args", toolCall.Function.Arguments)
+			toolCtx, toolSpan := tracing.Start(toolCtx, "tool.execute", trace.WithAttributes(
+				attribute.String("tool.name", functionName),
+				attribute.Bool("tool.mutating", tool.Mutating),
+				attribute.Int("agent.iteration", step+1),
+			))
+			started := time.Now()
+			toolResult, err := tool.Execute(toolCtx, toolCall.Function.Arguments)
+			tracing.End(toolSpan, err)
+			duration := time.Since(started)
+			durationMS := duration.Milliseconds()
+			metrics.ToolExecutions.WithLabelValues(functionName, metrics.Outcome(err)).Inc()
+			metrics.ToolDuration.WithLabelValues(functionName).Observe(duration.Seconds())
 			if err != nil {
-				log.Printf("Tool '%s' failed: %v", functionName, err)
+				slog.WarnContext(toolCtx, "Tool failed", "duration_ms", durationMS, "error", err)
 				toolResult = fmt.Sprintf("ERROR: %v", err)
 			} else {
-				log.Printf("Tool '%s' execution successful", functionName)
+				slog.InfoContext(toolCtx, "Tool succeeded", "duration_ms", durationMS, "mutating", tool.Mutating)
+				slog.DebugContext(toolCtx, "Tool result", "result", toolResult)
+			}
+
+			if onEvent != nil {
+				onEvent(Event{
+					Type:       EventToolResult,
+					Tool:       functionName,
+					ToolCallID: toolCall.ID,
+					Arguments:  toolCall.Function.Arguments,
+					Result:     toolResult,
+					Mutating:   tool.Mutating,
+					Err:        err,
+				})
 			}
 
 			toolResponses = append(toolResponses, openai.ChatCompletionMessage{
@@ -116,17 +143,22 @@ func (a *Agent) RunSessionConversation(ctx context.Context, messages []openai.Ch
 		}
 
 		messages = append(messages, toolResponses...)
-		log.Println("Step 3: Tool results appended to conversation for next LLM iteration")
 	}
 
+	metrics.RunIterations.Observe(float64(iterations))
+	span.SetAttributes(attribute.Int("agent.iterations", iterations))
+
+	// the model still wanted tools when the iterations ran out
 	if len(messages) > 0 && messages[len(messages)-1].Role != openai.ChatMessageRoleAssistant {
-		
+		metrics.RunsMaxIterations.Inc()
+		slog.WarnContext(ctx, "Agent run hit the iteration limit", "iterations", iterations)
+
 		messages = append(messages, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleUser,
 			Content: "Please provide a summary of what you've done and the current status.",
 		})
 		
-		resp, err := a.client.CreateChatCompletion(
+		resp, err := a.createChatCompletion(
 			ctx,
 			openai.ChatCompletionRequest{
 				Model:    a.model,
@@ -144,6 +176,38 @@ func (a *Agent) RunSessionConversation(ctx context.Context, messages []openai.Ch
 		}
 	}
 
-	log.Println("========== Agent session complete ==========")
+	slog.InfoContext(ctx, "Agent run complete")
 	return messages, nil
 }
+
+// createChatCompletion calls the model and records how long it took and the tokens it used.
+func (a *Agent) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	ctx, span := tracing.Start(ctx, "llm.chat_completion", trace.WithAttributes(
+		attribute.String("gen_ai.request.model", req.Model),
+		attribute.Int("gen_ai.request.messages", len(req.Messages)),
+		attribute.Int("gen_ai.request.tools", len(req.Tools)),
+	))
+
+	started := time.Now()
+	resp, err := a.client.CreateChatCompletion(ctx, req)
+	metrics.LLMRequestDuration.WithLabelValues(req.Model, metrics.Outcome(err)).Observe(time.Since(started).Seconds())
+	if err != nil {
+		tracing.End(span, err)
+		return resp, err
+	}
+
+	metrics.LLMTokens.WithLabelValues(req.Model, "prompt").Add(float64(resp.Usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(req.Model, "completion").Add(float64(resp.Usage.CompletionTokens))
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+	)
+	if len(resp.Choices) > 0 {
+		span.SetAttributes(
+			attribute.String("gen_ai.response.finish_reason", string(resp.Choices[0].FinishReason)),
+			attribute.Int("gen_ai.response.tool_calls", len(resp.Choices[0].Message.ToolCalls)),
+		)
+	}
+	tracing.End(span, nil)
+	return resp, nil
+}
@@ -3,10 +3,12 @@ package llm
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"sync"
 
 	system "gollama/config"
+	"gollama/tracing"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -30,20 +32,39 @@ func GetAgent(modelName string) (*Agent, error) {
 	// token is not needed for local Ollama, but required in openai library
 	config := openai.DefaultConfig("") 
 	config.BaseURL = system.ENV.BaseURL
+	config.HTTPClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
 
 	client := openai.NewClientWithConfig(config)
 
 	_, err := client.ListModels(context.Background())
 	if err != nil {
-		log.Printf("Could not connect to Ollama at %s. Is it running?", config.BaseURL)
+		slog.Error("Could not connect to Ollama, is it running?", "base_url", config.BaseURL, "error", err)
 		return nil, fmt.Errorf("failed to connect to ollama: %w", err)
 	}
-	log.Println("Successfully connected to Ollama.")
+	slog.Info("Connected to Ollama", "base_url", config.BaseURL)
 
 	instance = &Agent{
-		client: client,
-		model:  modelName,
+		client:        client,
+		model:         modelName,
+		maxIterations: system.ENV.AgentMaxIterations,
 	}
 	
 	return instance, nil
 }
+
+// WithModel returns an agent that shares this agent's client but talks to another model.
+func (a *Agent) WithModel(modelName string) *Agent {
+	return &Agent{
+		client:        a.client,
+		model:         modelName,
+		maxIterations: a.maxIterations,
+	}
+}
+
+func (a *Agent) Model() string {
+	return a.model
+}
+
+func (a *Agent) ListModels(ctx context.Context) (openai.ModelsList, error) {
+	return a.client.ListModels(ctx)
+}
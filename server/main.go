@@ -1,11 +1,82 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"gollama/routes"
 	"gollama/config"
+	"gollama/logging"
+	"gollama/sandbox"
+	"gollama/tracing"
 )
 
+// how long in-flight requests get to finish, and buffered spans to be sent, on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	router := routes.Master()
-	router.Run(":"+config.ENV.Port)
+	// a command started in the sandbox runs this same binary first, and never gets past here
+	sandbox.Init()
+
+	logging.Setup(config.ENV.LogFormat, config.ENV.LogLevel)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, config.ENV.TracingEndpoint, config.ENV.TracingServiceName)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:    ":" + config.ENV.Port,
+		Handler: routes.Master(),
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		slog.Error("Failed to listen", "addr", server.Addr, "error", err)
+		shutdown(server, shutdownTracing)
+		os.Exit(1)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	slog.Info("Listening", "addr", server.Addr)
+
+	select {
+	case err := <-serverErr:
+		slog.Error("Server failed", "error", err)
+		shutdown(server, shutdownTracing)
+		os.Exit(1)
+	case <-ctx.Done():
+		// a second signal kills the process right away
+		stop()
+		slog.Info("Shutting down")
+		shutdown(server, shutdownTracing)
+	}
+}
+
+// shutdown waits for in-flight requests and flushes the buffered spans. WebSocket
+// connections are hijacked and not waited for, their clients reconnect and replay.
+func shutdown(server *http.Server, shutdownTracing func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Failed to shut down the server gracefully", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
 }
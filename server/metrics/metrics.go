@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gollama"
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of chat completion calls by model and outcome.",
+		// local models on modest hardware take tens of seconds
+		Buckets: []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80, 160},
+	}, []string{"model", "outcome"})
+
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by chat completion calls by model and type, prompt or completion.",
+	}, []string{"model", "type"})
+
+	RunIterations = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "agent_run_iterations",
+		Help:      "Model calls with tools per agent run.",
+		Buckets:   []float64{1, 2, 3, 4, 6, 8, 10, 15, 20, 25, 30, 40, 50},
+	})
+
+	RunsMaxIterations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "agent_runs_max_iterations_total",
+		Help:      "Agent runs cut off at the iteration limit while the model still wanted tools.",
+	})
+
+	ToolExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_executions_total",
+		Help:      "Tool executions by tool and outcome.",
+	}, []string{"tool", "outcome"})
+
+	ToolDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Tool execution time by tool.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 15, 60, 300},
+	}, []string{"tool"})
+
+	GithubRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_api_requests_total",
+		Help:      "GitHub API requests by method and status code, 0 when no response came back.",
+	}, []string{"method", "status"})
+
+	GithubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_rate_limit_remaining",
+		Help:      "Requests left in the current GitHub rate limit window, by resource.",
+	}, []string{"resource"})
+
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Open WebSocket connections.",
+	})
+)
+
+// ObserveSessions reports count as the number of live chat sessions whenever metrics
+// are scraped.
+func ObserveSessions(count func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Chat sessions held by the session manager.",
+	}, func() float64 {
+		return float64(count())
+	})
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Outcome labels err.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
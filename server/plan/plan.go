@@ -0,0 +1,249 @@
+package plan
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	StatusProposed  = "proposed"
+	StatusApproved  = "approved"
+	StatusCompleted = "completed"
+)
+
+const (
+	StepPending  = "pending"
+	StepDone     = "done"
+	StepDiverged = "diverged"
+	StepFailed   = "failed"
+)
+
+var ErrEmptyPlan = errors.New("plan has no steps")
+
+// Step is one intended action. Tool, Files and Branch are what execution is checked against.
+type Step struct {
+	Number        int      `json:"number"`
+	Description   string   `json:"description"`
+	Tool          string   `json:"tool,omitempty"`
+	Files         []string `json:"files,omitempty"`
+	Branch        string   `json:"branch,omitempty"`
+	CommitMessage string   `json:"commit_message,omitempty"`
+	Status        string   `json:"status"`
+	ToolCallID    string   `json:"tool_call_id,omitempty"`
+	Note          string   `json:"note,omitempty"`
+}
+
+// Call is a changing tool call that no step accounts for.
+type Call struct {
+	Tool       string `json:"tool"`
+	Target     string `json:"target,omitempty"`
+	ToolCallID string `json:"tool_call_id"`
+}
+
+type Plan struct {
+	ID         string    `json:"id"`
+	Summary    string    `json:"summary"`
+	Repo       string    `json:"repo,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	Steps      []Step    `json:"steps"`
+	Unplanned  []Call    `json:"unplanned,omitempty"`
+	Status     string    `json:"status"`
+	RunID      string    `json:"run_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ApprovedAt time.Time `json:"approved_at,omitzero"`
+}
+
+type proposal struct {
+	Summary string `json:"summary"`
+	Repo    string `json:"repo"`
+	Branch  string `json:"branch"`
+	Steps   []struct {
+		Description   string   `json:"description"`
+		Tool          string   `json:"tool"`
+		Files         []string `json:"files"`
+		Branch        string   `json:"branch"`
+		CommitMessage string   `json:"commit_message"`
+	} `json:"steps"`
+}
+
+// Parse builds a proposed plan from the propose_plan tool's arguments.
+func Parse(arguments string) (*Plan, error) {
+	var parsed proposal
+	if err := json.Unmarshal([]byte(arguments), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(parsed.Steps) == 0 {
+		return nil, ErrEmptyPlan
+	}
+
+	now := time.Now()
+	p := &Plan{
+		ID:        generatePlanID(),
+		Summary:   parsed.Summary,
+		Repo:      parsed.Repo,
+		Branch:    parsed.Branch,
+		Status:    StatusProposed,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for i, step := range parsed.Steps {
+		if strings.TrimSpace(step.Description) == "" {
+			return nil, fmt.Errorf("step %d has no description", i+1)
+		}
+
+		// only steps that commit somewhere inherit the plan's branch
+		branch := step.Branch
+		if branch == "" && (len(step.Files) > 0 || step.CommitMessage != "") {
+			branch = parsed.Branch
+		}
+
+		p.Steps = append(p.Steps, Step{
+			Number:        i + 1,
+			Description:   step.Description,
+			Tool:          step.Tool,
+			Files:         step.Files,
+			Branch:        branch,
+			CommitMessage: step.CommitMessage,
+			Status:        StepPending,
+		})
+	}
+
+	return p, nil
+}
+
+func (p *Plan) Approve() {
+	p.Status = StatusApproved
+	p.ApprovedAt = time.Now()
+	p.UpdatedAt = p.ApprovedAt
+}
+
+// Track checks an executed tool call against the plan. The call completes the first
+// open step for the same tool and target. A call for a planned tool on another file or
+// branch marks that step diverged, and a changing call no step mentions is kept as
+// unplanned. Only calls made while the plan is approved count, not those before the
+// user approved it or after it was completed. It reports whether the plan changed.
+func (p *Plan) Track(tool string, arguments string, toolCallID string, mutating bool, callErr error) bool {
+	if p.Status != StatusApproved {
+		return false
+	}
+
+	path, branch := target(arguments)
+
+	var candidates []int
+	for i, step := range p.Steps {
+		if step.Tool == tool && (step.Status == StepPending || step.Status == StepFailed) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if !mutating {
+			return false
+		}
+		p.Unplanned = append(p.Unplanned, Call{
+			Tool:       tool,
+			Target:     describeTarget(path, branch),
+			ToolCallID: toolCallID,
+		})
+		p.UpdatedAt = time.Now()
+		return true
+	}
+
+	index := candidates[0]
+	matched := false
+	for _, i := range candidates {
+		if p.Steps[i].matches(path, branch) {
+			index = i
+			matched = true
+			break
+		}
+	}
+
+	step := &p.Steps[index]
+	step.ToolCallID = toolCallID
+	switch {
+	case callErr != nil:
+		step.Status = StepFailed
+		step.Note = callErr.Error()
+	case matched:
+		step.Status = StepDone
+		step.Note = ""
+	default:
+		step.Status = StepDiverged
+		step.Note = fmt.Sprintf("ran on %s instead", describeTarget(path, branch))
+	}
+
+	if !slices.ContainsFunc(p.Steps, func(s Step) bool { return s.Status == StepPending || s.Status == StepFailed }) {
+		p.Status = StatusCompleted
+	}
+	p.UpdatedAt = time.Now()
+	return true
+}
+
+func (s Step) matches(path string, branch string) bool {
+	if len(s.Files) > 0 && path != "" && !slices.Contains(s.Files, path) {
+		return false
+	}
+	if s.Branch != "" && branch != "" && s.Branch != branch {
+		return false
+	}
+	return true
+}
+
+func (p *Plan) Clone() *Plan {
+	clone := *p
+	clone.Steps = make([]Step, len(p.Steps))
+	for i, step := range p.Steps {
+		step.Files = slices.Clone(step.Files)
+		clone.Steps[i] = step
+	}
+	clone.Unplanned = slices.Clone(p.Unplanned)
+	return &clone
+}
+
+// target pulls the file and branch a tool call works on out of its arguments.
+func target(arguments string) (string, string) {
+	var args struct {
+		Path       string `json:"path"`
+		Branch     string `json:"branch"`
+		BranchName string `json:"branch_name"`
+		Head       string `json:"head"`
+	}
+	json.Unmarshal([]byte(arguments), &args)
+
+	branch := args.Branch
+	if branch == "" {
+		branch = args.BranchName
+	}
+	if branch == "" {
+		branch = args.Head
+	}
+	return args.Path, branch
+}
+
+func describeTarget(path string, branch string) string {
+	switch {
+	case path != "" && branch != "":
+		return path + " on " + branch
+	case path != "":
+		return path
+	case branch != "":
+		return branch
+	default:
+		return "a different target"
+	}
+}
+
+func generatePlanID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
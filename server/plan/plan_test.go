@@ -0,0 +1,164 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+const proposedPlan = `{
+	"summary": "Fix the typo",
+	"repo": "acme/demo",
+	"branch": "fix-typo",
+	"steps": [
+		{"description": "Create the branch", "tool": "create_branch"},
+		{"description": "Fix README", "tool": "update_file", "files": ["README.md"], "commit_message": "Fix typo"},
+		{"description": "Open the pull request", "tool": "create_github_pr"}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	p, err := Parse(proposedPlan)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.Status != StatusProposed || len(p.Steps) != 3 {
+		t.Fatalf("got status %s with %d steps", p.Status, len(p.Steps))
+	}
+	// only the committing step inherits the plan's branch
+	if p.Steps[0].Branch != "" || p.Steps[1].Branch != "fix-typo" || p.Steps[2].Branch != "" {
+		t.Fatalf("step branches %q %q %q", p.Steps[0].Branch, p.Steps[1].Branch, p.Steps[2].Branch)
+	}
+
+	if _, err := Parse(`{"summary": "nothing", "steps": []}`); !errors.Is(err, ErrEmptyPlan) {
+		t.Fatalf("Parse without steps: got %v, want ErrEmptyPlan", err)
+	}
+	if _, err := Parse(`{"steps": [{"description": " "}]}`); err == nil {
+		t.Fatal("Parse accepted a step without description")
+	}
+}
+
+func TestTrack(t *testing.T) {
+	type call struct {
+		tool      string
+		arguments string
+		mutating  bool
+		err       error
+	}
+
+	tests := []struct {
+		name      string
+		approve   bool
+		calls     []call
+		changed   bool
+		steps     []string
+		unplanned int
+		status    string
+	}{
+		{
+			name:    "not approved yet",
+			approve: false,
+			calls:   []call{{tool: "create_branch", arguments: `{"branch_name": "fix-typo"}`, mutating: true}},
+			changed: false,
+			steps:   []string{StepPending, StepPending, StepPending},
+			status:  StatusProposed,
+		},
+		{
+			name:    "matching call completes its step",
+			approve: true,
+			calls:   []call{{tool: "create_branch", arguments: `{"branch_name": "fix-typo"}`, mutating: true}},
+			changed: true,
+			steps:   []string{StepDone, StepPending, StepPending},
+			status:  StatusApproved,
+		},
+		{
+			name:    "other file diverges",
+			approve: true,
+			calls:   []call{{tool: "update_file", arguments: `{"path": "main.go", "branch": "fix-typo"}`, mutating: true}},
+			changed: true,
+			steps:   []string{StepPending, StepDiverged, StepPending},
+			status:  StatusApproved,
+		},
+		{
+			name:    "other branch diverges",
+			approve: true,
+			calls:   []call{{tool: "update_file", arguments: `{"path": "README.md", "branch": "main"}`, mutating: true}},
+			changed: true,
+			steps:   []string{StepPending, StepDiverged, StepPending},
+			status:  StatusApproved,
+		},
+		{
+			name:    "failed call fails the step and a retry completes it",
+			approve: true,
+			calls: []call{
+				{tool: "update_file", arguments: `{"path": "README.md", "branch": "fix-typo"}`, mutating: true, err: errors.New("conflict")},
+				{tool: "update_file", arguments: `{"path": "README.md", "branch": "fix-typo"}`, mutating: true},
+			},
+			changed: true,
+			steps:   []string{StepPending, StepDone, StepPending},
+			status:  StatusApproved,
+		},
+		{
+			name:      "unplanned changing call is recorded",
+			approve:   true,
+			calls:     []call{{tool: "workspace_push", arguments: `{"owner": "acme", "repo": "demo"}`, mutating: true}},
+			changed:   true,
+			steps:     []string{StepPending, StepPending, StepPending},
+			unplanned: 1,
+			status:    StatusApproved,
+		},
+		{
+			name:    "unplanned read is ignored",
+			approve: true,
+			calls:   []call{{tool: "get_repository_files", arguments: `{"path": "README.md"}`}},
+			changed: false,
+			steps:   []string{StepPending, StepPending, StepPending},
+			status:  StatusApproved,
+		},
+		{
+			name:    "last step completes the plan and later calls are ignored",
+			approve: true,
+			calls: []call{
+				{tool: "create_branch", arguments: `{"branch_name": "fix-typo"}`, mutating: true},
+				{tool: "update_file", arguments: `{"path": "README.md", "branch": "fix-typo"}`, mutating: true},
+				{tool: "create_github_pr", arguments: `{"head": "fix-typo"}`, mutating: true},
+				{tool: "update_file", arguments: `{"path": "README.md", "branch": "fix-typo"}`, mutating: true},
+			},
+			changed: false,
+			steps:   []string{StepDone, StepDone, StepDone},
+			status:  StatusCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(proposedPlan)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if tt.approve {
+				p.Approve()
+			}
+
+			var changed bool
+			for i, c := range tt.calls {
+				changed = p.Track(c.tool, c.arguments, fmt.Sprintf("call_%d", i), c.mutating, c.err)
+			}
+
+			if changed != tt.changed {
+				t.Errorf("last Track reported changed %v, want %v", changed, tt.changed)
+			}
+			for i, want := range tt.steps {
+				if got := p.Steps[i].Status; got != want {
+					t.Errorf("step %d is %s, want %s", i+1, got, want)
+				}
+			}
+			if len(p.Unplanned) != tt.unplanned {
+				t.Errorf("got %d unplanned calls, want %d", len(p.Unplanned), tt.unplanned)
+			}
+			if p.Status != tt.status {
+				t.Errorf("plan is %s, want %s", p.Status, tt.status)
+			}
+		})
+	}
+}
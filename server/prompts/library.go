@@ -0,0 +1,247 @@
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultPersona is used by sessions that never picked one.
+const DefaultPersona = "default"
+
+// how often the prompts directory is checked for changes
+const reloadInterval = 2 * time.Second
+
+var ErrUnknownPersona = errors.New("unknown persona")
+
+//go:embed personas/*.tmpl
+var builtin embed.FS
+
+// a leading {{/* ... */}} comment is the persona's description
+var descriptionPattern = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+
+// Data is what a persona template can refer to.
+type Data struct {
+	User    string
+	Repo    string
+	Repos   []string
+	Date    string
+	Tools   []string
+	Persona string
+}
+
+type Persona struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Builtin     bool   `json:"builtin"`
+}
+
+type persona struct {
+	Persona
+	template *template.Template
+}
+
+// Library holds the persona templates. Built-in personas ship with the binary; *.tmpl
+// files in the prompts directory add personas or override built-ins of the same name
+// and are picked up without a restart.
+type Library struct {
+	dir      string
+	personas map[string]*persona
+	modTimes map[string]time.Time
+	mu       sync.RWMutex
+}
+
+func NewLibrary(dir string) (*Library, error) {
+	l := &Library{
+		dir:      dir,
+		modTimes: make(map[string]time.Time),
+	}
+
+	personas, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.personas = personas
+
+	if dir != "" {
+		go l.watch()
+	}
+
+	return l, nil
+}
+
+// Render executes the persona's template. An unknown persona falls back to the default.
+func (l *Library) Render(name string, data Data) (string, error) {
+	l.mu.RLock()
+	p, exists := l.personas[name]
+	if !exists {
+		p = l.personas[DefaultPersona]
+	}
+	l.mu.RUnlock()
+
+	data.Persona = p.Name
+	if data.Repo == "" && len(data.Repos) > 0 {
+		data.Repo = data.Repos[0]
+	}
+	if data.Date == "" {
+		data.Date = time.Now().Format("Monday, January 2, 2006")
+	}
+
+	var out bytes.Buffer
+	if err := p.template.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render persona %s: %w", p.Name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func (l *Library) Has(name string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, exists := l.personas[name]
+	return exists
+}
+
+func (l *Library) Personas() []Persona {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := make([]Persona, 0, len(l.personas))
+	for _, p := range l.personas {
+		list = append(list, p.Persona)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// load parses the built-in personas, then the ones in the prompts directory.
+func (l *Library) load() (map[string]*persona, error) {
+	personas := make(map[string]*persona)
+
+	entries, err := fs.Glob(builtin, "personas/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list built-in personas: %w", err)
+	}
+	for _, path := range entries {
+		content, err := builtin.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read built-in persona %s: %w", path, err)
+		}
+		p, err := parsePersona(path, string(content))
+		if err != nil {
+			return nil, err
+		}
+		p.Builtin = true
+		personas[p.Name] = p
+	}
+
+	if l.dir == "" {
+		return personas, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personas in %s: %w", l.dir, err)
+	}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read persona %s: %w", path, err)
+		}
+		p, err := parsePersona(path, string(content))
+		if err != nil {
+			return nil, err
+		}
+		personas[p.Name] = p
+	}
+
+	return personas, nil
+}
+
+func parsePersona(path string, content string) (*persona, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse persona %s: %w", path, err)
+	}
+
+	p := &persona{
+		Persona:  Persona{Name: name},
+		template: tmpl,
+	}
+	if match := descriptionPattern.FindStringSubmatch(content); match != nil {
+		p.Description = match[1]
+	}
+	return p, nil
+}
+
+// watch reloads the personas whenever a template in the prompts directory is added,
+// changed or removed. A template that doesn't parse keeps the previous version live.
+func (l *Library) watch() {
+	l.changed()
+
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !l.changed() {
+			continue
+		}
+
+		personas, err := l.load()
+		if err != nil {
+			slog.Error("Failed to reload prompt templates, keeping the previous ones", "dir", l.dir, "error", err)
+			continue
+		}
+
+		l.mu.Lock()
+		l.personas = personas
+		l.mu.Unlock()
+		slog.Info("Reloaded prompt templates", "dir", l.dir)
+	}
+}
+
+// changed reports whether the set of templates or any modification time differs
+// from the last check.
+func (l *Library) changed() bool {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.tmpl"))
+	if err != nil {
+		return false
+	}
+
+	modTimes := make(map[string]time.Time, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	changed := len(modTimes) != len(l.modTimes)
+	for path, modTime := range modTimes {
+		if !l.modTimes[path].Equal(modTime) {
+			changed = true
+		}
+	}
+
+	l.modTimes = modTimes
+	return changed
+}
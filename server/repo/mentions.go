@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	repoURLPattern  = regexp.MustCompile(`github\.com/([A-Za-z0-9-]+)/([A-Za-z0-9._-]+)`)
+	bareRepoPattern = regexp.MustCompile("(?:^|[\\s(`'\"])([A-Za-z0-9][A-Za-z0-9-]{0,38})/([A-Za-z0-9._-]{1,100})(?:$|[\\s),:;!?`'\"]|\\.(?:\\s|$))")
+)
+
+// Mentioned returns the owner/repo names referenced in text, either as GitHub URLs or
+// as bare owner/repo. Bare matches can be plain paths, so callers should check that
+// the repository exists before relying on it.
+func Mentioned(text string) []string {
+	var repos []string
+
+	add := func(owner string, name string) {
+		name = strings.TrimSuffix(strings.TrimSuffix(name, "."), ".git")
+		if name == "" {
+			return
+		}
+		fullName := owner + "/" + name
+		if !slices.Contains(repos, fullName) {
+			repos = append(repos, fullName)
+		}
+	}
+
+	for _, match := range repoURLPattern.FindAllStringSubmatch(text, -1) {
+		add(match[1], match[2])
+	}
+
+	withoutURLs := repoURLPattern.ReplaceAllString(text, "")
+	for _, match := range bareRepoPattern.FindAllStringSubmatch(withoutURLs, -1) {
+		name := strings.TrimSuffix(match[2], ".")
+		// file paths like cmd/main.go are not repositories
+		if strings.Contains(name, ".") && !strings.HasSuffix(name, ".git") {
+			continue
+		}
+		add(match[1], name)
+	}
+
+	return repos
+}
@@ -0,0 +1,69 @@
+package repomap
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps maps by repository and commit SHA, a commit's map never changes.
+type Cache struct {
+	maps map[string]*cachedMap
+	mu   sync.Mutex
+}
+
+type cachedMap struct {
+	m        *Map
+	lastUsed time.Time
+}
+
+func NewCache() *Cache {
+	c := &Cache{maps: make(map[string]*cachedMap)}
+
+	go c.cleanupMaps()
+
+	return c
+}
+
+// Get returns the map of owner/repo at sha, generating it from dir on first use.
+func (c *Cache) Get(dir string, owner string, repo string, ref string, sha string) (*Map, error) {
+	key := owner + "/" + repo + "@" + sha
+
+	c.mu.Lock()
+	cached, exists := c.maps[key]
+	if exists {
+		cached.lastUsed = time.Now()
+		c.mu.Unlock()
+
+		// the same commit may have been asked for under another name
+		m := *cached.m
+		m.Ref = ref
+		return &m, nil
+	}
+	c.mu.Unlock()
+
+	m, err := Generate(dir, owner, repo, ref, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.maps[key] = &cachedMap{m: m, lastUsed: time.Now()}
+	c.mu.Unlock()
+
+	return m, nil
+}
+
+func (c *Cache) cleanupMaps() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		for key, cached := range c.maps {
+			if time.Since(cached.lastUsed) > 2*time.Hour {
+				delete(c.maps, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
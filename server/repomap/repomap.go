@@ -0,0 +1,241 @@
+package repomap
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gollama/codeintel"
+)
+
+const (
+	// the map rides along with every turn, so it has to stay compact
+	maxMapBytes      = 12 * 1024
+	maxTreeDepth     = 3
+	maxTreeEntries   = 80
+	maxFileSymbols   = 12
+	maxWalkedEntries = 50000
+)
+
+// directories that are generated, vendored or otherwise not worth mapping
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"__pycache__":  true,
+}
+
+// files that tell how a repository is built, run and configured
+var keyFileNames = map[string]bool{
+	"go.mod":             true,
+	"package.json":       true,
+	"Cargo.toml":         true,
+	"pyproject.toml":     true,
+	"requirements.txt":   true,
+	"Makefile":           true,
+	"Dockerfile":         true,
+	"docker-compose.yml": true,
+	".gollama.json":      true,
+	".gollama.md":        true,
+	"AGENTS.md":          true,
+	"main.go":            true,
+	".env.example":       true,
+}
+
+// Map is the outline of a repository at one commit.
+type Map struct {
+	Owner       string    `json:"owner"`
+	Repo        string    `json:"repo"`
+	Ref         string    `json:"ref"`
+	SHA         string    `json:"sha"`
+	Markdown    string    `json:"markdown"`
+	Truncated   bool      `json:"truncated"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// SystemMessage presents the map to the model.
+func (m *Map) SystemMessage() string {
+	return fmt.Sprintf("Repository map of %s/%s at %s (%s). Use it to find your way around before reading files:\n\n%s",
+		m.Owner, m.Repo, m.Ref, shortSHA(m.SHA), m.Markdown)
+}
+
+type directory struct {
+	path  string
+	depth int
+	files int
+}
+
+// Generate outlines the checkout in dir: what the README says the project is, the
+// directory tree, the files that tell how it's built and the top-level symbols of each
+// source file.
+func Generate(dir string, owner string, repo string, ref string, sha string) (*Map, error) {
+	var dirs []*directory
+	byPath := map[string]*directory{".": {path: ".", depth: 0}}
+	dirs = append(dirs, byPath["."])
+	var keyFiles, sourceFiles []string
+	walked := 0
+
+	err := filepath.WalkDir(dir, func(full string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, full)
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		walked++
+		if walked > maxWalkedEntries {
+			return filepath.SkipAll
+		}
+
+		name := entry.Name()
+		if entry.IsDir() {
+			if strings.HasPrefix(name, ".") || skippedDirs[name] {
+				return filepath.SkipDir
+			}
+			d := &directory{path: rel, depth: strings.Count(rel, "/") + 1}
+			byPath[rel] = d
+			dirs = append(dirs, d)
+			return nil
+		}
+
+		if parent, ok := byPath[path.Dir(rel)]; ok {
+			parent.files++
+		}
+		if keyFileNames[name] || (path.Dir(rel) == "." && strings.HasPrefix(strings.ToLower(name), "readme")) {
+			keyFiles = append(keyFiles, rel)
+		}
+		if sourceLanguage(name) != "" {
+			sourceFiles = append(sourceFiles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+
+	symbols, err := fileSymbols(dir, sourceFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	if summary := readmeSummary(dir); summary != "" {
+		fmt.Fprintf(&b, "## About\n\n%s\n\n", summary)
+	}
+
+	b.WriteString("## Layout\n\n```\n")
+	shown := 0
+	for _, d := range dirs {
+		if d.depth > maxTreeDepth {
+			continue
+		}
+		if shown == maxTreeEntries {
+			b.WriteString("...\n")
+			break
+		}
+		name := path.Base(d.path) + "/"
+		if d.path == "." {
+			name = "./"
+		}
+		fmt.Fprintf(&b, "%s%s (%s)\n", strings.Repeat("  ", d.depth), name, plural(d.files, "file"))
+		shown++
+	}
+	b.WriteString("```\n\n")
+
+	if len(keyFiles) > 0 {
+		b.WriteString("## Key files\n\n")
+		for _, file := range keyFiles {
+			fmt.Fprintf(&b, "- %s\n", file)
+		}
+		b.WriteString("\n")
+	}
+
+	truncated := false
+	if len(symbols) > 0 {
+		b.WriteString("## Symbols\n\n")
+		for _, file := range sourceFiles {
+			names := symbols[file]
+			if len(names) == 0 {
+				continue
+			}
+			line := fmt.Sprintf("- %s: %s\n", file, symbolList(names))
+			if b.Len()+len(line) > maxMapBytes {
+				truncated = true
+				b.WriteString("- ... more files, use go_list_symbols or workspace_search for the rest\n")
+				break
+			}
+			b.WriteString(line)
+		}
+	}
+
+	return &Map{
+		Owner:       owner,
+		Repo:        repo,
+		Ref:         ref,
+		SHA:         sha,
+		Markdown:    strings.TrimSpace(b.String()),
+		Truncated:   truncated,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// fileSymbols collects the top-level names of each source file, parsed properly for Go
+// and matched line by line for other languages.
+func fileSymbols(dir string, files []string) (map[string][]string, error) {
+	symbols := make(map[string][]string)
+
+	goIndex, err := codeintel.Build(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, symbol := range goIndex.Symbols {
+		if !symbol.Exported || symbol.Kind == codeintel.KindMethod || strings.HasSuffix(symbol.File, "_test.go") {
+			continue
+		}
+		symbols[symbol.File] = append(symbols[symbol.File], symbol.Name)
+	}
+
+	for _, file := range files {
+		if sourceLanguage(file) == "go" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			continue
+		}
+		symbols[file] = scriptSymbols(file, string(content))
+	}
+	return symbols, nil
+}
+
+func symbolList(names []string) string {
+	sort.Strings(names)
+	if len(names) <= maxFileSymbols {
+		return strings.Join(names, ", ")
+	}
+	return strings.Join(names[:maxFileSymbols], ", ") + fmt.Sprintf(" (+%d more)", len(names)-maxFileSymbols)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,118 @@
+package repomap
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const maxReadmeSummary = 800
+
+var (
+	scriptPatterns = map[string]*regexp.Regexp{
+		"javascript": regexp.MustCompile(`(?m)^export\s+(?:default\s+)?(?:async\s+)?(?:function\*?|class|const|let|var|interface|type|enum)\s+([A-Za-z_$][\w$]*)`),
+		"python":     regexp.MustCompile(`(?m)^(?:async\s+)?(?:def|class)\s+([A-Za-z_]\w*)`),
+	}
+	// badges, images, html and rules say nothing about what a project does
+	readmeNoise = regexp.MustCompile(`^(\[!\[|!\[|<|\||[-*_=]{3,}$)`)
+)
+
+// sourceLanguage is the language whose symbols the map lists for a file, empty for
+// files it doesn't look into.
+func sourceLanguage(name string) string {
+	if strings.HasSuffix(name, ".min.js") || strings.HasSuffix(name, ".d.ts") {
+		return ""
+	}
+	switch path.Ext(name) {
+	case ".go":
+		return "go"
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs":
+		return "javascript"
+	case ".py":
+		return "python"
+	}
+	return ""
+}
+
+func scriptSymbols(file string, content string) []string {
+	pattern, ok := scriptPatterns[sourceLanguage(file)]
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+		// private by convention
+		if strings.HasPrefix(match[1], "_") {
+			continue
+		}
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// readmeSummary is the opening prose of the root README, without headings, badges and
+// code.
+func readmeSummary(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(strings.ToLower(entry.Name()), "readme") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return ""
+		}
+		return summarize(string(content))
+	}
+	return ""
+}
+
+func summarize(readme string) string {
+	var paragraphs []string
+	var current []string
+	inCode := false
+
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+
+	for _, line := range strings.Split(readme, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			flush()
+			continue
+		}
+		if inCode || trimmed == "" || strings.HasPrefix(trimmed, "#") || readmeNoise.MatchString(trimmed) {
+			flush()
+			continue
+		}
+		current = append(current, trimmed)
+	}
+	flush()
+
+	summary := ""
+	for _, paragraph := range paragraphs {
+		if summary != "" && len(summary)+len(paragraph) > maxReadmeSummary {
+			break
+		}
+		if summary != "" {
+			summary += "\n\n"
+		}
+		summary += paragraph
+	}
+	if len(summary) > maxReadmeSummary {
+		summary = summary[:maxReadmeSummary] + "..."
+	}
+	return summary
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"gollama/audit"
+	"gollama/auth"
+	"gollama/config"
+	"gollama/llm"
+	"gollama/tools"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditLog *audit.Log
+
+// recordAudit appends entry to the audit log, filling in who and which run from ctx.
+// A failed write is logged but doesn't undo or fail the side effect it describes.
+func recordAudit(ctx context.Context, entry audit.Entry) {
+	run := tools.RunContextFrom(ctx)
+	if entry.User == "" {
+		entry.User = run.User
+	}
+	if entry.SessionID == "" {
+		entry.SessionID = run.SessionID
+	}
+	if entry.RunID == "" {
+		entry.RunID = run.RunID
+	}
+	if entry.Prompt == "" {
+		entry.Prompt = run.Prompt
+	}
+
+	if _, err := auditLog.Append(entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit entry", "action", entry.Action, "error", err)
+	}
+}
+
+// auditToolResult records a finished mutating tool call. Dry runs change nothing, so
+// they're left out.
+func auditToolResult(ctx context.Context, event llm.Event) {
+	if event.Type != llm.EventToolResult || !event.Mutating || tools.RunContextFrom(ctx).DryRun {
+		return
+	}
+
+	args := audit.Arguments(event.Arguments)
+	entry := audit.Entry{
+		Action:    event.Tool,
+		Repo:      audit.Repo(args),
+		Arguments: args,
+	}
+	if event.Err != nil {
+		entry.Outcome = audit.OutcomeError
+		entry.Error = event.Err.Error()
+	} else {
+		entry.Result = audit.ResultRefs(event.Result)
+	}
+
+	recordAudit(ctx, entry)
+}
+
+// AuditLogHandler lists audit entries, newest first. Admins can query every user,
+// everyone else only sees their own entries.
+func AuditLogHandler(c *gin.Context) {
+	user := auth.User(c)
+
+	filter := audit.Filter{
+		User:      c.Query("user"),
+		SessionID: c.Query("session_id"),
+		Repo:      c.Query("repo"),
+		Action:    c.Query("action"),
+	}
+	if !slices.Contains(config.ENV.AuditAdmins, user) {
+		filter.User = user
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time"})
+			return
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be an RFC 3339 time"})
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+	}
+
+	result, err := auditLog.Query(filter)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to query audit log", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the audit log"})
+		return
+	}
+	if !result.Verified {
+		slog.WarnContext(c.Request.Context(), "Audit log hash chain is broken", "seq", result.BrokenAt)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gollama/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const authCookieTTL = 7 * 24 * time.Hour
+
+// AuthSessionHandler exchanges a bearer token for a signed cookie, so browsers can open
+// the WebSocket without putting the token in the URL.
+func AuthSessionHandler(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, err := authenticator.SignCookie(auth.User(c), time.Now().Add(authCookieTTL))
+		if err != nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Cookie sessions are not configured"})
+			return
+		}
+
+		c.SetSameSite(http.SameSiteStrictMode)
+		c.SetCookie(auth.CookieName, value, int(authCookieTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+		c.JSON(http.StatusOK, gin.H{"user": auth.User(c)})
+	}
+}
+
+// requestLogger is gin's request log with the access token taken out of the logged path.
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Formatter: func(param gin.LogFormatterParams) string {
+			var statusColor, methodColor, resetColor string
+			if param.IsOutputColor() {
+				statusColor = param.StatusCodeColor()
+				methodColor = param.MethodColor()
+				resetColor = param.ResetColor()
+			}
+
+			if param.Latency > time.Minute {
+				param.Latency = param.Latency.Truncate(time.Second)
+			}
+			return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				statusColor, param.StatusCode, resetColor,
+				param.Latency,
+				param.ClientIP,
+				methodColor, param.Method, resetColor,
+				auth.RedactToken(param.Path),
+				param.ErrorMessage,
+			)
+		},
+	})
+}
@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"gollama/auth"
+	"gollama/chat"
+	"gollama/jobs"
+	"gollama/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+type editMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// EditMessageHandler replaces a user message, keeps the old continuation as a branch
+// and re-runs the agent from the edited message.
+func EditMessageHandler(c *gin.Context) {
+	var req editMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index must be a number"})
+		return
+	}
+
+	job, err := editMessage(c.Request.Context(), auth.User(c), c.Param("id"), index, req.Content, nil)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"session_id": job.SessionID, "job_id": job.ID})
+}
+
+// RegenerateHandler re-runs the agent on the last user message, keeping the previous
+// answer as a branch.
+func RegenerateHandler(c *gin.Context) {
+	job, err := regenerate(c.Request.Context(), auth.User(c), c.Param("id"), nil)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"session_id": job.SessionID, "job_id": job.ID})
+}
+
+func SwitchBranchHandler(c *gin.Context) {
+	transcript, err := switchBranch(auth.User(c), c.Param("id"), c.Param("branch"))
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transcript)
+}
+
+func editMessage(ctx context.Context, user string, sessionID string, index int, content string, attach func()) (*jobs.Job, error) {
+	chatSession, err := rewindSession(user, sessionID, func(chatSession *chat.ChatSession) error {
+		return chatSession.EditMessage(index, content)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if attach != nil {
+		attach()
+	}
+	publishTranscript(chatSession)
+
+	return startTurn(ctx, sessionID, user, content, nil)
+}
+
+func regenerate(ctx context.Context, user string, sessionID string, attach func()) (*jobs.Job, error) {
+	var prompt string
+	chatSession, err := rewindSession(user, sessionID, func(chatSession *chat.ChatSession) error {
+		var err error
+		prompt, err = chatSession.Regenerate()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if attach != nil {
+		attach()
+	}
+	publishTranscript(chatSession)
+
+	// the user message is already last on the session, so the run won't add it twice
+	return startTurn(ctx, sessionID, user, prompt, nil)
+}
+
+func switchBranch(user string, sessionID string, branchID string) (chat.Transcript, error) {
+	chatSession, err := rewindSession(user, sessionID, func(chatSession *chat.ChatSession) error {
+		return chatSession.SwitchBranch(branchID)
+	})
+	if err != nil {
+		return chat.Transcript{}, err
+	}
+
+	return publishTranscript(chatSession), nil
+}
+
+// rewindSession applies a history rewrite to the session, refusing while a run is in
+// progress so the run doesn't append to a path that no longer exists.
+func rewindSession(user string, sessionID string, rewrite func(*chat.ChatSession) error) (*chat.ChatSession, error) {
+	chatSession, err := sessionManager.GetSession(sessionID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockIdleSession(chatSession)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := rewrite(chatSession); err != nil {
+		return nil, err
+	}
+	return chatSession, nil
+}
+
+// lockIdleSession holds off runs while the session's history or side effects change. It
+// refuses while a run executes and also while one is queued or waiting for a retry,
+// which would otherwise run after the change instead of before it.
+func lockIdleSession(chatSession *chat.ChatSession) (func(), error) {
+	if jobQueue.Busy(chatSession.ID) {
+		return nil, chat.ErrSessionBusy
+	}
+
+	unlock, ok := chatSession.TryLockRun()
+	if !ok {
+		return nil, chat.ErrSessionBusy
+	}
+	return unlock, nil
+}
+
+// publishTranscript tells every attached client to redraw the conversation.
+func publishTranscript(chatSession *chat.ChatSession) chat.Transcript {
+	transcript := chatSession.Transcript()
+	streamHub.Stream(chatSession.ID).Publish(socket.Message{
+		Command: "transcript",
+		Data:    transcript,
+	})
+	return transcript
+}
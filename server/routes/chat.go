@@ -1,21 +1,43 @@
 package routes
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	mathRand "math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"gollama/auth"
 	"gollama/llm"
 	"gollama/chat"
+	"gollama/config"
+	"gollama/githubapi"
+	"gollama/jobs"
+	"gollama/logging"
+	"gollama/repo"
 	"gollama/socket"
+	"gollama/tools"
+	"gollama/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var sessionManager = chat.NewSessionManager()
+var (
+	sessionManager = chat.NewSessionManager()
+	streamHub      = socket.NewHub()
+	forwardedJobs  sync.Map
+)
 
 const (
 	// MODEL = "qwen2.5-coder:7b"
@@ -23,6 +45,20 @@ const (
 	MODEL = "gpt-oss:20b"
 )
 
+const dryRunNotice = "This session is in dry-run mode. create_github_branch, update_github_file and " +
+	"create_github_pr only simulate their changes and return a preview; nothing is written to GitHub. " +
+	"Work as usual, and make clear in your summary that the changes were simulated."
+
+const (
+	// more than a few conventions files crowd out the conversation itself
+	maxRepoInstructions     = 3
+	repoInstructionsTimeout = 10 * time.Second
+	// the first map of a repository needs a clone
+	repoMapTimeout          = 2 * time.Minute
+	// bigger repositories are only mapped when the agent asks for it
+	maxRepoMapSizeKB        = 100 * 1024
+)
+
 func generateSessionID() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
@@ -30,9 +66,11 @@ func generateSessionID() string {
 }
 
 func WebSocketHandler(c *gin.Context) {
+	user := auth.User(c)
+
 	conn, err := socket.NewConnection(c)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		slog.WarnContext(c.Request.Context(), "WebSocket upgrade failed", "user", user, "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to upgrade to WebSocket"})
 		return
 	}
@@ -44,33 +82,222 @@ func WebSocketHandler(c *gin.Context) {
 	})
 
 	conn.ReadPump(func(conn *socket.Connection, msg socket.Message) {
+		ctx, span := tracing.Start(conn.Context(), "websocket.message", trace.WithAttributes(
+			attribute.String("session.id", msg.SessionID),
+			attribute.String("websocket.command", msg.Command),
+		))
+		defer span.End()
+
+		if msg.Command != "" {
+			handleCommand(ctx, conn, user, msg)
+			return
+		}
+
+		if msg.Content == "" {
+			// a client that only knows the job re-attaches to its events
+			if msg.JobID != "" {
+				go streamJob(conn, user, msg.JobID, msg.JobSeq)
+				return
+			}
+
+			// a reconnecting client resumes its session and replays the frames it missed
+			if _, err := sessionManager.GetSession(msg.SessionID, user); err != nil {
+				conn.SendMessage(socket.Message{
+					Error:     "Unknown session",
+					SessionID: msg.SessionID,
+				})
+				return
+			}
+			conn.Follow(streamHub.Stream(msg.SessionID), msg.LastSeq)
+			return
+		}
+
 		sessionID := msg.SessionID
 		if sessionID == "" {
 			sessionID = generateSessionID()
 		}
 
-		chatSession := sessionManager.GetOrCreateSession(sessionID)
-
-		userMessage := openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: msg.Content,
+		stream := streamHub.Stream(sessionID)
+		lastSeq := msg.LastSeq
+		if lastSeq == 0 {
+			lastSeq = stream.Seq()
 		}
-		chatSession.AddMessage(userMessage)
 
-		agent, err := llm.GetAgent(MODEL)
-		if err != nil {
-			log.Printf("Error creating agent: %v", err)
+		span.SetAttributes(attribute.String("session.id", sessionID))
+		if _, err := startTurn(ctx, sessionID, user, msg.Content, func() { conn.Follow(stream, lastSeq) }); err != nil {
+			span.SetStatus(codes.Error, err.Error())
 			conn.SendMessage(socket.Message{
-				Error:     "Failed to initialize LLM agent",
+				Error:     turnErrorMessage(err),
 				SessionID: sessionID,
 			})
+		}
+	})
+}
+
+// startTurn claims the session for user and queues an agent run for content. attach runs
+// once the session is known to belong to the user, before the first frame is published.
+func startTurn(ctx context.Context, sessionID string, user string, content string, attach func()) (*jobs.Job, error) {
+	if _, err := sessionManager.GetOrCreateSession(sessionID, user); err != nil {
+		return nil, err
+	}
+
+	if attach != nil {
+		attach()
+	}
+
+	job, err := jobQueue.Enqueue(ctx, jobs.SourceChat, sessionID, user, content, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to enqueue job", "session_id", sessionID, "user", user, "error", err)
+		return nil, err
+	}
+
+	streamHub.Stream(sessionID).Publish(socket.Message{
+		JobID:        job.ID,
+		IsProcessing: true,
+	})
+
+	forwardJobEvents(job)
+	return job, nil
+}
+
+func turnErrorMessage(err error) string {
+	if errors.Is(err, chat.ErrSessionForbidden) {
+		return "Unknown session"
+	}
+	return "Too many requests in flight, please try again in a moment"
+}
+
+// forwardJobEvents publishes a job's events into its session stream until the job
+// finishes, independent of whether any client is connected.
+func forwardJobEvents(job *jobs.Job) {
+	if _, forwarding := forwardedJobs.LoadOrStore(job.ID, true); forwarding {
+		return
+	}
+
+	go func() {
+		defer forwardedJobs.Delete(job.ID)
+
+		stream := streamHub.Stream(job.SessionID)
+		lastSeq := 0
+
+		for {
+			missed, events, cancel, err := jobQueue.Subscribe(job.ID, lastSeq)
+			if err != nil {
+				return
+			}
+
+			for _, event := range missed {
+				stream.Publish(jobEventMessage(job, event))
+				lastSeq = event.Seq
+			}
+			for event := range events {
+				stream.Publish(jobEventMessage(job, event))
+				lastSeq = event.Seq
+			}
+			cancel()
+
+			// the channel also closes when we fall behind, so only stop once everything is out
+			current, exists := jobQueue.Get(job.ID)
+			if !exists || current.Done() && lastSeq >= len(current.Events) {
+				return
+			}
+		}
+	}()
+}
+
+// streamJob forwards a job's events after afterSeq to the connection until the job
+// finishes or the client goes away.
+func streamJob(conn *socket.Connection, user string, jobID string, afterSeq int) {
+	job, exists := jobQueue.Get(jobID)
+	if !exists || job.User != user {
+		conn.SendMessage(socket.Message{
+			Error: "Unknown job",
+			JobID: jobID,
+		})
+		return
+	}
+
+	missed, events, cancel, err := jobQueue.Subscribe(jobID, afterSeq)
+	if err != nil {
+		return
+	}
+	defer cancel()
+
+	for _, event := range missed {
+		conn.SendMessage(jobEventMessage(job, event))
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			conn.SendMessage(jobEventMessage(job, event))
+		case <-conn.Context().Done():
 			return
 		}
+	}
+}
+
+func jobEventMessage(job *jobs.Job, event jobs.Event) socket.Message {
+	msg := socket.Message{
+		SessionID: job.SessionID,
+		JobID:     job.ID,
+		JobSeq:    event.Seq,
+	}
+
+	switch event.Type {
+	case jobs.EventStatus:
+		msg.Response = event.Message
+		msg.IsProcessing = true
+	case jobs.EventResult:
+		msg.Response = event.Message
+	case jobs.EventError:
+		// the full error stays in the logs and on the job status endpoint
+		msg.Error = "An error occurred while processing your request"
+	}
+
+	return msg
+}
+
+// runJob is the job queue's runner: one agent turn on the job's session.
+func runJob(ctx context.Context, job *jobs.Job, emit func(eventType, message string)) (response string, err error) {
+	ctx, span := tracing.Start(ctx, "job.run", trace.WithAttributes(
+		attribute.String("session.id", job.SessionID),
+		attribute.String("job.id", job.ID),
+		attribute.String("job.source", job.Source),
+		attribute.Int("job.attempt", job.Attempts),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	// a job is one run, so its ID doubles as the run ID
+	ctx = logging.With(ctx, "session_id", job.SessionID, "run_id", job.ID, "user", job.User, "source", job.Source)
+
+	forwardJobEvents(job)
+
+	chatSession, err := jobSession(job)
+	if err != nil {
+		return "", err
+	}
+
+	unlock := chatSession.LockRun()
+	defer unlock()
+
+	response = completedReply(chatSession, job)
+	if response == "" {
+		run := chatSession.RunContext(job.ID)
+		run.Prompt = job.Prompt
+		ctx = tools.WithRunContext(ctx, run)
 
 		toolsUsed := false
-		
-		statusCallback := func(status string) {
-			if !toolsUsed && status == "tools_used" {
+
+		onEvent := func(event llm.Event) {
+			switch event.Type {
+			case llm.EventToolCalls:
+				if toolsUsed {
+					return
+				}
 				toolsUsed = true
 				messages := []string{
 					"Working on it… apparently this takes more than two seconds.",
@@ -79,49 +306,245 @@ func WebSocketHandler(c *gin.Context) {
 					"Working through the steps… hang tight.",
 					"The tools and I are having a deep conversation.",
 				}
-				msgText := messages[mathRand.Intn(len(messages))]
-				conn.SendMessage(socket.Message{
-					Response:     msgText,
-					SessionID:    sessionID,
-					IsProcessing: true,
-				})
+				emit(jobs.EventStatus, messages[mathRand.Intn(len(messages))])
+
+			case llm.EventToolResult:
+				trackPlan(chatSession, event)
+				auditToolResult(ctx, event)
 			}
 		}
 
-		updatedMessages, err := agent.RunSessionConversation(conn.Context(), chatSession.GetMessages(), statusCallback)
-		if err != nil {
-			log.Printf("Error during conversation: %v", err)
-			conn.SendMessage(socket.Message{
-				Error:     "An error occurred while processing your request",
-				SessionID: sessionID,
-			})
-			return
+		response, err = runAgentTurn(ctx, chatSession, job.Prompt, job.Attempts > 1, onEvent)
+	}
+
+	if job.Source == jobs.SourceWebhook {
+		return response, postWebhookResult(ctx, job, response, err)
+	}
+
+	return response, err
+}
+
+// jobSession returns the session a job runs in. A chat turn continues a conversation,
+// which must still exist; a webhook job starts its own from the event.
+func jobSession(job *jobs.Job) (*chat.ChatSession, error) {
+	if job.Source == jobs.SourceWebhook {
+		return sessionManager.GetOrCreateSession(job.SessionID, job.User)
+	}
+	return sessionManager.GetSession(job.SessionID, job.User)
+}
+
+// resumableJob tells the job queue which jobs left over from before a restart can still
+// run. Sessions live in memory, so chat turns can't.
+func resumableJob(job *jobs.Job) bool {
+	if job.Source == jobs.SourceWebhook {
+		return true
+	}
+	_, err := sessionManager.GetSession(job.SessionID, job.User)
+	return err == nil
+}
+
+// completedReply returns the answer from an earlier attempt of the same job when only
+// a later step (like posting a webhook comment) failed, so retries don't rerun the agent.
+func completedReply(chatSession *chat.ChatSession, job *jobs.Job) string {
+	if job.Attempts < 2 {
+		return ""
+	}
+
+	messages := chatSession.GetMessages()
+	last := messages[len(messages)-1]
+	if last.Role != openai.ChatMessageRoleAssistant || last.Content == "" {
+		return ""
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			if messages[i].Content == job.Prompt {
+				return last.Content
+			}
+			break
 		}
+	}
 
-		for _, message := range updatedMessages[len(chatSession.GetMessages()):] {
+	return ""
+}
+
+// runAgentTurn appends the user's message to the session, runs the agent over the
+// conversation and stores everything it produced, also when it fails. It returns the
+// final assistant reply. A retry continues after the steps of the failed attempt, so
+// their side effects aren't repeated.
+func runAgentTurn(ctx context.Context, chatSession *chat.ChatSession, content string, retry bool, onEvent func(llm.Event)) (string, error) {
+	// a retried job already added its message on the failed attempt
+	messages := chatSession.GetMessages()
+	last := messages[len(messages)-1]
+	added := last.Role == openai.ChatMessageRoleUser && last.Content == content
+	if retry && lastUserContent(messages) == content {
+		added = true
+	}
+	if !added {
+		chatSession.AddMessage(openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		})
+	}
+
+	agent, err := llm.GetAgent(MODEL)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize LLM agent: %w", err)
+	}
+
+	linked := chatSession.GetRepos()
+	instructions := repoInstructions(ctx, chatSession, content)
+	instructions = append(instructions, repoMaps(ctx, chatSession, linked)...)
+
+	// rendered every turn so template edits, the date and newly linked repos show up
+	chatSession.SetSystemPrompt(systemPrompt(chatSession.GetPersona(), chatSession.Owner, chatSession.GetRepos()))
+	history := chatSession.GetMessages()
+
+	// the mode the run started in, toggling it mid-run only affects the next one
+	if tools.RunContextFrom(ctx).DryRun {
+		instructions = append(instructions, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: dryRunNotice,
+		})
+	}
+
+	// the instructions ride along after the system prompt but are never stored, so
+	// every turn sees the file as of the current default branch commit
+	messages = make([]openai.ChatCompletionMessage, 0, len(history)+len(instructions))
+	messages = append(messages, history[0])
+	messages = append(messages, instructions...)
+	messages = append(messages, history[1:]...)
+
+	updatedMessages, err := agent.RunSessionConversation(ctx, messages, onEvent)
+	if len(updatedMessages) > len(messages) {
+		for _, message := range updatedMessages[len(messages):] {
 			chatSession.AddMessage(message)
 		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if lastAssistantMessage := lastAssistantContent(updatedMessages); lastAssistantMessage != "" {
+		return lastAssistantMessage, nil
+	}
+
+	return "I've completed all requested operations. Check the repository for the changes.", nil
+}
+
+// repoInstructions links the repositories named in the message to the session and
+// returns the conventions files of the session's trusted repositories, the ones it opened
+// a workspace for or TRUSTED_REPOS names, as quoted context. Naming a repository is
+// not enough, its file is written by whoever can push to it. A repository that can't
+// be read never fails the turn.
+func repoInstructions(ctx context.Context, chatSession *chat.ChatSession, content string) []openai.ChatCompletionMessage {
+	ctx, cancel := context.WithTimeout(ctx, repoInstructionsTimeout)
+	defer cancel()
+
+	candidates := chatSession.GetRepos()
+	for _, mentioned := range repo.Mentioned(content) {
+		if !slices.Contains(candidates, mentioned) {
+			candidates = append(candidates, mentioned)
+		}
+	}
+
+	opened := chatSession.OpenedRepos()
+
+	var messages []openai.ChatCompletionMessage
+	for _, fullName := range candidates {
+		if len(messages) == maxRepoInstructions {
+			break
+		}
+
+		owner, name, _ := strings.Cut(fullName, "/")
+		instructions, err := repo.LoadInstructions(ctx, owner, name)
+		if errors.Is(err, repo.ErrRepoNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to load repository instructions", "repo", fullName, "error", err)
+			continue
+		}
+
+		// a bare owner/repo in the message only counts once GitHub knows it
+		chatSession.LinkRepo(fullName)
+
+		if instructions == nil || !(slices.Contains(opened, fullName) || repo.Trusted(fullName, config.ENV.TrustedRepos)) {
+			continue
+		}
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: instructions.ContextMessage(),
+		})
+	}
+
+	return messages
+}
 
-		var lastAssistantMessage string
-		for i := len(updatedMessages) - 1; i >= 0; i-- {
-			if updatedMessages[i].Role == openai.ChatMessageRoleAssistant {
-				lastAssistantMessage = updatedMessages[i].Content
-				break
+// repoMaps starts outlining the first repository linked since the turn started, in the
+// background so the reply doesn't wait for the clone, and returns the outlines taken so
+// far. A map that's ready after the turn started goes along from the next turn on.
+func repoMaps(ctx context.Context, chatSession *chat.ChatSession, linkedBefore []string) []openai.ChatCompletionMessage {
+	if config.ENV.RepoMapAuto && len(chatSession.GetRepoMaps()) < maxRepoInstructions {
+		for _, fullName := range chatSession.GetRepos() {
+			if slices.Contains(linkedBefore, fullName) {
+				continue
 			}
+			// one clone per turn, however many repositories the message names
+			go mapRepo(context.WithoutCancel(ctx), chatSession, fullName)
+			break
 		}
+	}
 
-		if lastAssistantMessage != "" {
-			conn.SendMessage(socket.Message{
-				Response:     lastAssistantMessage,
-				SessionID:    sessionID,
-				IsProcessing: false,
-			})
-		} else {
-			conn.SendMessage(socket.Message{
-				Response:     "I've completed all requested operations. Check the repository for the changes.",
-				SessionID:    sessionID,
-				IsProcessing: false,
-			})
+	var messages []openai.ChatCompletionMessage
+	for _, outline := range chatSession.GetRepoMaps() {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: outline,
+		})
+	}
+	return messages
+}
+
+// mapRepo clones and outlines fullName for the session, unless it's too big to clone
+// unasked.
+func mapRepo(ctx context.Context, chatSession *chat.ChatSession, fullName string) {
+	ctx, cancel := context.WithTimeout(ctx, repoMapTimeout)
+	defer cancel()
+
+	owner, name, _ := strings.Cut(fullName, "/")
+	repository, _, err := githubapi.Client().Repositories.Get(ctx, owner, name)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to look up repository to map", "repo", fullName, "error", err)
+		return
+	}
+	if repository.GetSize() > maxRepoMapSizeKB {
+		slog.InfoContext(ctx, "Repository is too big to map automatically", "repo", fullName, "size_kb", repository.GetSize())
+		return
+	}
+
+	m, err := tools.RepoMap(ctx, owner, name, "")
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to map repository", "repo", fullName, "error", err)
+		return
+	}
+	chatSession.SetRepoMap(fullName, m.SystemMessage())
+}
+
+func lastAssistantContent(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleAssistant {
+			return messages[i].Content
 		}
-	})
+	}
+	return ""
+}
+
+func lastUserContent(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
 }
@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"gollama/chat"
+	"gollama/jobs"
+	"gollama/prompts"
+	"gollama/socket"
+)
+
+var errUnknownCommand = errors.New("unknown command")
+
+type commandArgs struct {
+	Title    string `json:"title"`
+	Index    *int   `json:"index"`
+	Format   string `json:"format"`
+	Content  string `json:"content"`
+	BranchID string `json:"branch_id"`
+	Persona  string `json:"persona"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// handleCommand answers the session management commands sent over the WebSocket.
+// Replies go to the requesting connection only, with the command echoed back.
+func handleCommand(ctx context.Context, conn *socket.Connection, user string, msg socket.Message) {
+	var args commandArgs
+	if len(msg.Args) > 0 {
+		if err := json.Unmarshal(msg.Args, &args); err != nil {
+			conn.SendMessage(socket.Message{Command: msg.Command, SessionID: msg.SessionID, Error: "Invalid command arguments"})
+			return
+		}
+	}
+
+	var data any
+	var err error
+
+	switch msg.Command {
+	case "list_sessions":
+		data = sessionManager.ListSessions(user)
+
+	case "rename_session":
+		if args.Title == "" {
+			err = errors.New("title is required")
+			break
+		}
+		data, err = renameSession(user, msg.SessionID, args.Title)
+
+	case "delete_session":
+		err = deleteSession(user, msg.SessionID)
+
+	case "fork_session":
+		if args.Index == nil {
+			err = errors.New("index is required")
+			break
+		}
+		data, err = forkSession(user, msg.SessionID, *args.Index)
+
+	case "export_session":
+		var chatSession *chat.ChatSession
+		chatSession, err = sessionManager.GetSession(msg.SessionID, user)
+		if err != nil {
+			break
+		}
+		transcript := chatSession.Transcript()
+		if args.Format == "markdown" {
+			data = transcript.Markdown()
+		} else {
+			data = transcript
+		}
+
+	case "get_transcript":
+		var chatSession *chat.ChatSession
+		chatSession, err = sessionManager.GetSession(msg.SessionID, user)
+		if err == nil {
+			data = chatSession.Transcript()
+		}
+
+	case "edit_message":
+		if args.Index == nil || args.Content == "" {
+			err = errors.New("index and content are required")
+			break
+		}
+		stream := streamHub.Stream(msg.SessionID)
+		_, err = editMessage(ctx, user, msg.SessionID, *args.Index, args.Content, func() { conn.Follow(stream, stream.Seq()) })
+
+	case "regenerate":
+		stream := streamHub.Stream(msg.SessionID)
+		_, err = regenerate(ctx, user, msg.SessionID, func() { conn.Follow(stream, stream.Seq()) })
+
+	case "switch_branch":
+		data, err = switchBranch(user, msg.SessionID, args.BranchID)
+
+	case "get_plan":
+		var chatSession *chat.ChatSession
+		chatSession, err = sessionManager.GetSession(msg.SessionID, user)
+		if err == nil {
+			data = chatSession.GetPlan()
+		}
+
+	case "approve_plan":
+		stream := streamHub.Stream(msg.SessionID)
+		data, _, err = approvePlan(ctx, user, msg.SessionID, func() { conn.Follow(stream, stream.Seq()) })
+
+	case "list_personas":
+		data = promptLibrary.Personas()
+
+	case "set_persona":
+		data, err = setPersona(user, msg.SessionID, args.Persona)
+
+	case "undo":
+		data, err = undoLastRun(ctx, user, msg.SessionID)
+
+	case "get_journal":
+		var chatSession *chat.ChatSession
+		chatSession, err = sessionManager.GetSession(msg.SessionID, user)
+		if err == nil {
+			data = chatSession.Journal().Actions()
+		}
+
+	case "set_dry_run":
+		if args.Enabled == nil {
+			err = errors.New("enabled is required")
+			break
+		}
+		data, err = setDryRun(user, msg.SessionID, *args.Enabled)
+
+	default:
+		err = errUnknownCommand
+	}
+
+	reply := socket.Message{
+		Command:   msg.Command,
+		SessionID: msg.SessionID,
+		Data:      data,
+	}
+	if err != nil {
+		reply.Error = commandErrorMessage(err)
+	}
+	conn.SendMessage(reply)
+}
+
+func commandErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, chat.ErrSessionNotFound), errors.Is(err, chat.ErrSessionForbidden):
+		return "Unknown session"
+	case errors.Is(err, chat.ErrInvalidIndex):
+		return "Message index out of range"
+	case errors.Is(err, chat.ErrNotUserMessage):
+		return "Only user messages can be edited"
+	case errors.Is(err, chat.ErrNothingToRedo):
+		return "Nothing to regenerate"
+	case errors.Is(err, chat.ErrBranchNotFound):
+		return "Branch not found"
+	case errors.Is(err, prompts.ErrUnknownPersona):
+		return "Unknown persona"
+	case errors.Is(err, chat.ErrNothingToUndo):
+		return "Nothing to undo"
+	case errors.Is(err, chat.ErrNoPlan):
+		return "There is no plan for this session"
+	case errors.Is(err, chat.ErrPlanNotProposed):
+		return "The plan is not waiting for approval"
+	case errors.Is(err, chat.ErrSessionBusy):
+		return "Wait for the current run to finish"
+	case errors.Is(err, jobs.ErrQueueFull):
+		return "Too many requests in flight, please try again in a moment"
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+
+	"gollama/auth"
+	"gollama/chat"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setDryRunRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+func SetDryRunHandler(c *gin.Context) {
+	var req setDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+
+	summary, err := setDryRun(auth.User(c), c.Param("id"), *req.Enabled)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// setDryRun switches dry-run mode from the next turn on. Like personas, it can be set
+// before the first message.
+func setDryRun(user string, sessionID string, enabled bool) (chat.SessionSummary, error) {
+	chatSession, err := sessionManager.GetOrCreateSession(sessionID, user)
+	if err != nil {
+		return chat.SessionSummary{}, err
+	}
+
+	chatSession.SetDryRun(enabled)
+	return chatSession.Summary(), nil
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"net/http"
+
+	"gollama/auth"
+	"gollama/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+var jobQueue *jobs.Queue
+
+func JobStatusHandler(c *gin.Context) {
+	job, exists := jobQueue.Get(c.Param("id"))
+	if !exists || job.User != auth.User(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         job.ID,
+		"source":     job.Source,
+		"session_id": job.SessionID,
+		"status":     job.Status,
+		"attempts":   job.Attempts,
+		"error":      job.Error,
+		"events":     len(job.Events),
+		"created_at": job.CreatedAt,
+		"updated_at": job.UpdatedAt,
+	})
+}
+
+func JobResultHandler(c *gin.Context) {
+	job, exists := jobQueue.Get(c.Param("id"))
+	if !exists || job.User != auth.User(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	if !job.Done() {
+		c.JSON(http.StatusAccepted, gin.H{"status": job.Status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": job.Status,
+		"result": job.Result,
+		"error":  job.Error,
+	})
+}
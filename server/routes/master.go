@@ -1,17 +1,119 @@
 package routes
 
 import (
+	"log/slog"
+	"os"
+
+	"gollama/audit"
+	"gollama/auth"
+	"gollama/config"
+	"gollama/jobs"
+	"gollama/metrics"
+	"gollama/prompts"
+	"gollama/webhook"
+
 	"github.com/gin-gonic/gin"
 )
 
 func Master() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(requestLogger(), gin.Recovery())
+
+	webhookRules, err := webhook.LoadRules(config.ENV.WebhookRulesFile)
+	if err != nil {
+		slog.Error("Failed to load webhook rules", "error", err)
+		os.Exit(1)
+	}
+
+	promptLibrary, err = prompts.NewLibrary(config.ENV.PromptsDir)
+	if err != nil {
+		slog.Error("Failed to load prompt templates", "error", err)
+		os.Exit(1)
+	}
+
+	// the server holds a GitHub token with write access, it doesn't start open to anyone
+	// by accident
+	authenticator := auth.New(config.ENV.AuthTokens, config.ENV.AuthCookieSecret, config.ENV.AuthDisabled)
+	if !authenticator.Enabled() && !config.ENV.AuthDisabled {
+		slog.Error("No credentials configured, set AUTH_TOKENS or AUTH_COOKIE_SECRET, or AUTH_DISABLED=true to let every request in as one anonymous user")
+		os.Exit(1)
+	}
+	requireAuth := authenticator.Middleware()
+
+	auditLog, err = audit.Open(config.ENV.AuditLog)
+	if err != nil {
+		slog.Error("Failed to open audit log", "error", err)
+		os.Exit(1)
+	}
+
+	jobStore, err := jobs.NewStore(config.ENV.JobsDir)
+	if err != nil {
+		slog.Error("Failed to open job store", "error", err)
+		os.Exit(1)
+	}
+	jobQueue = jobs.NewQueue(jobStore, config.ENV.JobWorkers, config.ENV.JobMaxAttempts, runJob)
+	if err := jobQueue.Start(resumableJob); err != nil {
+		slog.Error("Failed to start job queue", "error", err)
+		os.Exit(1)
+	}
 	
+	metrics.ObserveSessions(sessionManager.Count)
+
 	// system endpoints
 	router.GET("/health", HealthCheck)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// websocket endpoint
-	router.GET("/chat", WebSocketHandler)
+	router.GET("/chat", requireAuth, WebSocketHandler)
+
+	// rest and server-sent events chat api
+	router.POST("/sessions/:id/messages", requireAuth, SessionMessageHandler)
+	router.GET("/sessions/:id/events", requireAuth, SessionEventsHandler)
+	router.GET("/sessions/:id", requireAuth, SessionTranscriptHandler)
+
+	// session management
+	router.GET("/sessions", requireAuth, ListSessionsHandler)
+	router.PATCH("/sessions/:id", requireAuth, RenameSessionHandler)
+	router.DELETE("/sessions/:id", requireAuth, DeleteSessionHandler)
+	router.POST("/sessions/:id/fork", requireAuth, ForkSessionHandler)
+	router.GET("/sessions/:id/export", requireAuth, ExportSessionHandler)
+
+	// edit, regenerate and conversation branches
+	router.PUT("/sessions/:id/messages/:index", requireAuth, EditMessageHandler)
+	router.POST("/sessions/:id/regenerate", requireAuth, RegenerateHandler)
+	router.POST("/sessions/:id/branches/:branch/checkout", requireAuth, SwitchBranchHandler)
+
+	// structured plans
+	router.GET("/sessions/:id/plan", requireAuth, GetPlanHandler)
+	router.POST("/sessions/:id/plan/approve", requireAuth, ApprovePlanHandler)
+
+	// personas
+	router.GET("/personas", requireAuth, ListPersonasHandler)
+	router.PUT("/sessions/:id/persona", requireAuth, SetPersonaHandler)
+
+	// action journal and undo
+	router.GET("/sessions/:id/journal", requireAuth, JournalHandler)
+	router.POST("/sessions/:id/undo", requireAuth, UndoHandler)
+
+	// dry-run mode
+	router.PUT("/sessions/:id/dry-run", requireAuth, SetDryRunHandler)
+
+	// openai-compatible api
+	router.POST("/v1/chat/completions", requireAuth, ChatCompletionsHandler)
+	router.GET("/v1/models", requireAuth, ModelsHandler)
+
+	// auth endpoints
+	router.POST("/auth/session", requireAuth, AuthSessionHandler(authenticator))
+
+	// github webhook endpoint
+	router.POST("/webhooks/github", GithubWebhookHandler(webhookRules))
+
+	// audit log of repository side effects
+	router.GET("/audit", requireAuth, AuditLogHandler)
+
+	// background job endpoints
+	router.GET("/jobs/:id", requireAuth, JobStatusHandler)
+	router.GET("/jobs/:id/result", requireAuth, JobResultHandler)
 
 	return router
 }
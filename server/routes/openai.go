@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gollama/auth"
+	"gollama/llm"
+	"gollama/logging"
+	"gollama/tools"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+)
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+func openAIErrorResponse(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, gin.H{"error": openAIError{Message: message, Type: errType}})
+}
+
+// ChatCompletionsHandler is an OpenAI-compatible /v1/chat/completions. The server runs
+// the whole tool loop with its own tools and answers with the final assistant message,
+// so any OpenAI client gets the GitHub-aware agent. Tools sent by the client are ignored.
+// Requests without a system message get the persona named by the persona query parameter.
+func ChatCompletionsHandler(c *gin.Context) {
+	var req openai.ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+	if len(req.Messages) == 0 {
+		openAIErrorResponse(c, http.StatusBadRequest, "invalid_request_error", "messages must not be empty")
+		return
+	}
+
+	agent, err := llm.GetAgent(MODEL)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create agent", "error", err)
+		openAIErrorResponse(c, http.StatusServiceUnavailable, "server_error", "Failed to initialize LLM agent")
+		return
+	}
+	if req.Model != "" {
+		agent = agent.WithModel(req.Model)
+	}
+
+	messages := req.Messages
+	if messages[0].Role != openai.ChatMessageRoleSystem {
+		messages = append([]openai.ChatCompletionMessage{{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemPrompt(c.Query("persona"), auth.User(c), nil),
+		}}, messages...)
+	}
+
+	id := "chatcmpl-" + generateSessionID()
+	created := time.Now().Unix()
+
+	if req.Stream {
+		streamChatCompletion(c, agent, messages, id, created)
+		return
+	}
+
+	ctx := logging.With(completionRunContext(c, id, messages), "completion_id", id, "user", auth.User(c))
+	updatedMessages, err := agent.RunSessionConversation(ctx, messages, func(event llm.Event) { auditToolResult(ctx, event) })
+	if err != nil {
+		slog.ErrorContext(ctx, "Conversation failed", "error", err)
+		openAIErrorResponse(c, http.StatusBadGateway, "server_error", "An error occurred while processing your request")
+		return
+	}
+
+	c.JSON(http.StatusOK, openai.ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   agent.Model(),
+		Choices: []openai.ChatCompletionChoice{{
+			Index: 0,
+			Message: openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: lastAssistantContent(updatedMessages),
+			},
+			FinishReason: openai.FinishReasonStop,
+		}},
+	})
+}
+
+// streamChatCompletion answers in the OpenAI streaming format. The tool loop can take
+// minutes, so the role chunk goes out first and keep-alive comments hold the stream
+// open until the final content chunk.
+func streamChatCompletion(c *gin.Context, agent *llm.Agent, messages []openai.ChatCompletionMessage, id string, created int64) {
+	chunk := func(delta openai.ChatCompletionStreamChoiceDelta, finishReason openai.FinishReason) sse.Event {
+		return sse.Event{Data: openai.ChatCompletionStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   agent.Model(),
+			Choices: []openai.ChatCompletionStreamChoice{{
+				Index:        0,
+				Delta:        delta,
+				FinishReason: finishReason,
+			}},
+		}}
+	}
+
+	type result struct {
+		messages []openai.ChatCompletionMessage
+		err      error
+	}
+
+	ctx, cancel := context.WithCancel(logging.With(completionRunContext(c, id, messages), "completion_id", id, "user", auth.User(c)))
+	defer cancel()
+
+	done := make(chan result, 1)
+	go func() {
+		updatedMessages, err := agent.RunSessionConversation(ctx, messages, func(event llm.Event) { auditToolResult(ctx, event) })
+		done <- result{updatedMessages, err}
+	}()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Render(-1, chunk(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, ""))
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case res := <-done:
+			if res.err != nil {
+				slog.ErrorContext(ctx, "Conversation failed", "error", res.err)
+				c.Render(-1, sse.Event{Data: gin.H{"error": openAIError{
+					Message: "An error occurred while processing your request",
+					Type:    "server_error",
+				}}})
+				return false
+			}
+
+			c.Render(-1, chunk(openai.ChatCompletionStreamChoiceDelta{Content: lastAssistantContent(res.messages)}, ""))
+			c.Render(-1, chunk(openai.ChatCompletionStreamChoiceDelta{}, openai.FinishReasonStop))
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return false
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// completionRunContext identifies a completion to the tools, so its side effects are
+// audited under the caller and the last user message.
+func completionRunContext(c *gin.Context, id string, messages []openai.ChatCompletionMessage) context.Context {
+	run := tools.RunContext{RunID: id, User: auth.User(c)}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			run.Prompt = messages[i].Content
+			break
+		}
+	}
+	return tools.WithRunContext(c.Request.Context(), run)
+}
+
+// ModelsHandler lists the models served by the configured backend.
+func ModelsHandler(c *gin.Context) {
+	agent, err := llm.GetAgent(MODEL)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create agent", "error", err)
+		openAIErrorResponse(c, http.StatusServiceUnavailable, "server_error", "Failed to initialize LLM agent")
+		return
+	}
+
+	models, err := agent.ListModels(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to list models", "error", err)
+		openAIErrorResponse(c, http.StatusBadGateway, "server_error", "Failed to list models")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   models.Models,
+	})
+}
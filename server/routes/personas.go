@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"gollama/auth"
+	"gollama/chat"
+	"gollama/prompts"
+	"gollama/tools"
+
+	"github.com/gin-gonic/gin"
+)
+
+var promptLibrary *prompts.Library
+
+// systemPrompt renders the persona's template for a turn. Templates are user editable,
+// so one that fails to render falls back to the default persona.
+func systemPrompt(persona string, user string, repos []string) string {
+	toolNames := make([]string, 0)
+	for name := range tools.GetAvailableTools() {
+		toolNames = append(toolNames, name)
+	}
+	sort.Strings(toolNames)
+
+	data := prompts.Data{
+		User:  user,
+		Repos: repos,
+		Tools: toolNames,
+	}
+
+	prompt, err := promptLibrary.Render(persona, data)
+	if err == nil {
+		return prompt
+	}
+	slog.Error("Failed to render system prompt", "persona", persona, "error", err)
+
+	prompt, err = promptLibrary.Render(prompts.DefaultPersona, data)
+	if err != nil {
+		slog.Error("Failed to render default system prompt", "error", err)
+	}
+	return prompt
+}
+
+func ListPersonasHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"personas": promptLibrary.Personas()})
+}
+
+type setPersonaRequest struct {
+	Persona string `json:"persona" binding:"required"`
+}
+
+func SetPersonaHandler(c *gin.Context) {
+	var req setPersonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "persona is required"})
+		return
+	}
+
+	summary, err := setPersona(auth.User(c), c.Param("id"), req.Persona)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// setPersona switches the persona from the next turn on. The session is created if
+// needed, so a persona can be picked before the first message.
+func setPersona(user string, sessionID string, persona string) (chat.SessionSummary, error) {
+	if !promptLibrary.Has(persona) {
+		return chat.SessionSummary{}, prompts.ErrUnknownPersona
+	}
+
+	chatSession, err := sessionManager.GetOrCreateSession(sessionID, user)
+	if err != nil {
+		return chat.SessionSummary{}, err
+	}
+
+	chatSession.SetPersona(persona)
+	return chatSession.Summary(), nil
+}
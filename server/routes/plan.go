@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"gollama/auth"
+	"gollama/chat"
+	"gollama/llm"
+	"gollama/plan"
+	"gollama/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the message that starts the run once a plan is approved
+const planApprovedPrompt = "Approved. Go ahead and execute the plan step by step."
+
+// trackPlan stores plans proposed by the agent and checks every other tool call off
+// against the session's approved plan. Changes go out to attached clients as a plan frame.
+func trackPlan(chatSession *chat.ChatSession, event llm.Event) {
+	if event.Tool == "propose_plan" {
+		if event.Err != nil {
+			return
+		}
+		proposed, err := plan.Parse(event.Arguments)
+		if err != nil {
+			slog.Warn("Failed to parse proposed plan", "session_id", chatSession.ID, "error", err)
+			return
+		}
+		chatSession.SetPlan(proposed)
+		publishPlan(chatSession.ID, proposed)
+		return
+	}
+
+	updated, changed := chatSession.UpdatePlan(func(p *plan.Plan) bool {
+		return p.Track(event.Tool, event.Arguments, event.ToolCallID, event.Mutating, event.Err)
+	})
+	if changed {
+		publishPlan(chatSession.ID, updated)
+	}
+}
+
+func publishPlan(sessionID string, p *plan.Plan) {
+	streamHub.Stream(sessionID).Publish(socket.Message{
+		Command: "plan",
+		Data:    p,
+	})
+}
+
+func GetPlanHandler(c *gin.Context) {
+	chatSession, err := sessionManager.GetSession(c.Param("id"), auth.User(c))
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	current := chatSession.GetPlan()
+	if current == nil {
+		sessionErrorResponse(c, chat.ErrNoPlan)
+		return
+	}
+
+	c.JSON(http.StatusOK, current)
+}
+
+func ApprovePlanHandler(c *gin.Context) {
+	approved, jobID, err := approvePlan(c.Request.Context(), auth.User(c), c.Param("id"), nil)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"plan":   approved,
+		"job_id": jobID,
+	})
+}
+
+// approvePlan approves the session's proposed plan and starts the run that executes it,
+// recording that run on the plan so execution links back to what was approved.
+func approvePlan(ctx context.Context, user string, sessionID string, attach func()) (*plan.Plan, string, error) {
+	chatSession, err := sessionManager.GetSession(sessionID, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := chatSession.ApprovePlan(); err != nil {
+		return nil, "", err
+	}
+
+	job, err := startTurn(ctx, sessionID, user, planApprovedPrompt, attach)
+	if err != nil {
+		// nothing ran, so the plan is still waiting for a go-ahead
+		chatSession.UpdatePlan(func(p *plan.Plan) bool {
+			p.Status = plan.StatusProposed
+			return true
+		})
+		return nil, "", err
+	}
+
+	approved, _ := chatSession.UpdatePlan(func(p *plan.Plan) bool {
+		p.RunID = job.ID
+		return true
+	})
+	publishPlan(sessionID, approved)
+	return approved, job.ID, nil
+}
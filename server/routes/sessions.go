@@ -0,0 +1,250 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gollama/auth"
+	"gollama/chat"
+	"gollama/jobs"
+	"gollama/prompts"
+	"gollama/socket"
+	"gollama/tools"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// how often an idle event stream sends a comment so proxies keep it open
+const sseKeepAlive = 30 * time.Second
+
+type sessionMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// SessionMessageHandler starts an agent run for a message, the REST equivalent of
+// sending a message over the WebSocket. Progress is delivered on the events stream.
+func SessionMessageHandler(c *gin.Context) {
+	var req sessionMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	job, err := startTurn(c.Request.Context(), sessionID, auth.User(c), req.Content, nil)
+	if errors.Is(err, chat.ErrSessionForbidden) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many requests in flight, please try again in a moment"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"session_id": sessionID,
+		"job_id":     job.ID,
+	})
+}
+
+// SessionEventsHandler streams the session's frames as Server-Sent Events. Clients resume
+// with the standard Last-Event-ID header or a last_seq query parameter.
+func SessionEventsHandler(c *gin.Context) {
+	sessionID := c.Param("id")
+	if _, err := sessionManager.GetSession(sessionID, auth.User(c)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_seq")
+	}
+	lastSeq, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	missed, frames, cancel := streamHub.Stream(sessionID).Subscribe(lastSeq)
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, msg := range missed {
+		c.Render(-1, sseEvent(msg))
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg, ok := <-frames:
+			if !ok {
+				// fell behind, the client reconnects with Last-Event-ID
+				return false
+			}
+			c.Render(-1, sseEvent(msg))
+			return true
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func sseEvent(msg socket.Message) sse.Event {
+	return sse.Event{
+		Id:    strconv.FormatInt(msg.Seq, 10),
+		Event: "message",
+		Data:  msg,
+	}
+}
+
+// SessionTranscriptHandler returns the conversation so far, without the system prompt.
+func SessionTranscriptHandler(c *gin.Context) {
+	chatSession, err := sessionManager.GetSession(c.Param("id"), auth.User(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, chatSession.Transcript())
+}
+
+func ListSessionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sessions": sessionManager.ListSessions(auth.User(c))})
+}
+
+type renameSessionRequest struct {
+	Title string `json:"title" binding:"required"`
+}
+
+func RenameSessionHandler(c *gin.Context) {
+	var req renameSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+
+	summary, err := renameSession(auth.User(c), c.Param("id"), req.Title)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func DeleteSessionHandler(c *gin.Context) {
+	if err := deleteSession(auth.User(c), c.Param("id")); err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type forkSessionRequest struct {
+	Index *int `json:"index" binding:"required"`
+}
+
+func ForkSessionHandler(c *gin.Context) {
+	var req forkSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index is required"})
+		return
+	}
+
+	summary, err := forkSession(auth.User(c), c.Param("id"), *req.Index)
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, summary)
+}
+
+func ExportSessionHandler(c *gin.Context) {
+	chatSession, err := sessionManager.GetSession(c.Param("id"), auth.User(c))
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	transcript := chatSession.Transcript()
+	filename := "session-" + chatSession.ID
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, transcript)
+	case "markdown", "md":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.md"`)
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(transcript.Markdown()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or markdown"})
+	}
+}
+
+func renameSession(user string, sessionID string, title string) (chat.SessionSummary, error) {
+	chatSession, err := sessionManager.GetSession(sessionID, user)
+	if err != nil {
+		return chat.SessionSummary{}, err
+	}
+
+	chatSession.SetTitle(title)
+	return chatSession.Summary(), nil
+}
+
+func deleteSession(user string, sessionID string) error {
+	if err := sessionManager.DeleteSession(sessionID, user); err != nil {
+		return err
+	}
+
+	streamHub.Remove(sessionID)
+	tools.RemoveWorkspaces(sessionID)
+	return nil
+}
+
+func forkSession(user string, sessionID string, index int) (chat.SessionSummary, error) {
+	fork, err := sessionManager.ForkSession(sessionID, user, index, generateSessionID())
+	if err != nil {
+		return chat.SessionSummary{}, err
+	}
+
+	return fork.Summary(), nil
+}
+
+func sessionErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, chat.ErrInvalidIndex):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message index out of range"})
+	case errors.Is(err, chat.ErrNotUserMessage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only user messages can be edited"})
+	case errors.Is(err, chat.ErrNothingToRedo):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to regenerate"})
+	case errors.Is(err, chat.ErrNothingToUndo):
+		c.JSON(http.StatusConflict, gin.H{"error": "Nothing to undo"})
+	case errors.Is(err, chat.ErrNoPlan):
+		c.JSON(http.StatusNotFound, gin.H{"error": "There is no plan for this session"})
+	case errors.Is(err, chat.ErrPlanNotProposed):
+		c.JSON(http.StatusConflict, gin.H{"error": "The plan is not waiting for approval"})
+	case errors.Is(err, prompts.ErrUnknownPersona):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown persona"})
+	case errors.Is(err, chat.ErrBranchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+	case errors.Is(err, chat.ErrSessionBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": "Wait for the current run to finish"})
+	case errors.Is(err, jobs.ErrQueueFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many requests in flight, please try again in a moment"})
+	default:
+		// a session owned by someone else looks exactly like a missing one
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	}
+}
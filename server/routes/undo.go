@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gollama/audit"
+	"gollama/auth"
+	"gollama/chat"
+	"gollama/tools"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+)
+
+func JournalHandler(c *gin.Context) {
+	chatSession, err := sessionManager.GetSession(c.Param("id"), auth.User(c))
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": chatSession.Journal().Actions()})
+}
+
+func UndoHandler(c *gin.Context) {
+	report, err := undoLastRun(c.Request.Context(), auth.User(c), c.Param("id"))
+	if err != nil {
+		sessionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// undoLastRun reverts the side effects of the session's most recent run that still has
+// any. The outcome is added to the conversation so the agent knows what's gone.
+func undoLastRun(ctx context.Context, user string, sessionID string) (tools.UndoReport, error) {
+	chatSession, err := sessionManager.GetSession(sessionID, user)
+	if err != nil {
+		return tools.UndoReport{}, err
+	}
+
+	unlock, err := lockIdleSession(chatSession)
+	if err != nil {
+		return tools.UndoReport{}, err
+	}
+	defer unlock()
+
+	journal := chatSession.Journal()
+	runID, actions := journal.LastRun()
+	if len(actions) == 0 {
+		return tools.UndoReport{}, chat.ErrNothingToUndo
+	}
+
+	// pushes are reverted through the session's workspace
+	ctx = tools.WithRunContext(ctx, chatSession.RunContext(runID))
+	report := tools.Undo(ctx, runID, actions)
+	journal.MarkUndone(report.Undone)
+
+	recordAudit(ctx, audit.Entry{
+		User:   user,
+		Action: "undo",
+		Result: map[string]any{"reverted": report.Reverted, "skipped": report.Skipped},
+	})
+
+	chatSession.AddMessage(openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: undoSummary(report),
+	})
+	publishTranscript(chatSession)
+
+	return report, nil
+}
+
+func undoSummary(report tools.UndoReport) string {
+	var b strings.Builder
+	b.WriteString("I undid the changes from my last run.\n")
+	for _, line := range report.Reverted {
+		fmt.Fprintf(&b, "\n- %s", line)
+	}
+	if len(report.Skipped) > 0 {
+		b.WriteString("\n\nThese couldn't be reverted safely and need a look:\n")
+		for _, line := range report.Skipped {
+			fmt.Fprintf(&b, "\n- %s", line)
+		}
+	}
+	return b.String()
+}
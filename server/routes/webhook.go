@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gollama/audit"
+	"gollama/config"
+	"gollama/jobs"
+	"gollama/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v74/github"
+)
+
+// owner of the sessions created by webhook jobs
+const webhookUser = "github-webhook"
+
+var webhookDeliveries = webhook.NewDeliveries(24 * time.Hour)
+
+func GithubWebhookHandler(rules []webhook.Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.ENV.GithubWebhookSecret == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "GitHub webhooks are not configured"})
+			return
+		}
+
+		payload, err := github.ValidatePayload(c.Request, []byte(config.ENV.GithubWebhookSecret))
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Rejected GitHub webhook", "error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+			return
+		}
+
+		// claimed up front so concurrent redeliveries run once, and released again unless
+		// a job gets queued so GitHub's redelivery of a failed one isn't dropped
+		deliveryID := github.DeliveryID(c.Request)
+		if deliveryID != "" && webhookDeliveries.Seen(deliveryID) {
+			c.JSON(http.StatusOK, gin.H{"message": "Duplicate delivery ignored"})
+			return
+		}
+		queued := false
+		defer func() {
+			if deliveryID != "" && !queued {
+				webhookDeliveries.Forget(deliveryID)
+			}
+		}()
+
+		eventType := github.WebHookType(c.Request)
+		event, err := github.ParseWebHook(eventType, payload)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Unsupported event ignored"})
+			return
+		}
+
+		job, err := webhook.Match(rules, eventType, event)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to map GitHub event", "event", eventType, "delivery_id", deliveryID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to map event to a job"})
+			return
+		}
+		if job == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "No rule matched"})
+			return
+		}
+
+		// anyone can comment on a public repository, but runs write with the server's token
+		if err := webhook.Authorize(c.Request.Context(), job, config.ENV.WebhookAuthorAssociations); err != nil {
+			if errors.Is(err, webhook.ErrSenderNotAllowed) {
+				slog.WarnContext(c.Request.Context(), "Ignored GitHub event from unauthorized sender", "delivery_id", deliveryID, "rule", job.Rule, "sender", job.Sender, "error", err)
+				c.JSON(http.StatusOK, gin.H{"message": "Sender is not allowed to trigger the agent"})
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to authorize GitHub event", "delivery_id", deliveryID, "rule", job.Rule, "sender", job.Sender, "error", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to check the sender's permission"})
+			return
+		}
+
+		// one conversation per issue or pull request, so follow-up events keep the context
+		sessionID := fmt.Sprintf("github:%s/%s#%d", job.Owner, job.Repo, job.Number)
+
+		queuedJob, err := jobQueue.Enqueue(c.Request.Context(), jobs.SourceWebhook, sessionID, webhookUser, job.Prompt, job)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to enqueue webhook job", "session_id", sessionID, "delivery_id", deliveryID, "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue job"})
+			return
+		}
+
+		queued = true
+
+		slog.InfoContext(c.Request.Context(), "GitHub delivery matched rule, job queued", "delivery_id", deliveryID, "rule", job.Rule, "job_id", queuedJob.ID, "session_id", sessionID)
+		c.JSON(http.StatusAccepted, gin.H{"message": "Job queued", "rule": job.Rule, "job_id": queuedJob.ID})
+	}
+}
+
+// postWebhookResult comments the outcome of a webhook job on its issue or pull request.
+// Transient failures are left to the job queue to retry without commenting.
+func postWebhookResult(ctx context.Context, job *jobs.Job, response string, runErr error) error {
+	if runErr != nil && jobs.IsTransient(runErr) {
+		return runErr
+	}
+
+	var target webhook.Job
+	if err := json.Unmarshal(job.Payload, &target); err != nil {
+		return fmt.Errorf("failed to decode webhook job: %w", err)
+	}
+
+	if runErr != nil {
+		slog.ErrorContext(ctx, "Webhook job failed", "error", runErr)
+		response = "Sorry, I ran into an error while working on this. Check the server logs for details."
+	}
+
+	url, err := webhook.PostResult(ctx, &target, response)
+	entry := audit.Entry{
+		User:      job.User,
+		SessionID: job.SessionID,
+		RunID:     job.ID,
+		Prompt:    job.Prompt,
+		Action:    "webhook_comment",
+		Repo:      target.Owner + "/" + target.Repo,
+		Arguments: map[string]any{"number": target.Number, "body": audit.Hash(response)},
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeError
+		entry.Error = err.Error()
+	} else {
+		entry.Result = map[string]any{"url": url}
+	}
+	recordAudit(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	return runErr
+}
@@ -0,0 +1,289 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// output beyond this is cut from the middle of each stream
+const maxOutputBytes = 16 * 1024
+
+var (
+	ErrEmptyCommand      = errors.New("command is empty")
+	ErrCommandNotAllowed = errors.New("command is not allowed")
+)
+
+// environment variables a command inherits, everything else like tokens stays behind.
+// GOCACHE and TMPDIR aren't among them, they point into the command's scratch space.
+var passthroughEnv = []string{"PATH", "HOME", "LANG", "GOPATH", "GOMODCACHE", "GOFLAGS", "GOPROXY", "npm_config_cache"}
+
+// arguments that would mean something to a shell; commands never go through one, so
+// they're refused rather than silently passed on as plain words
+var shellSyntax = []string{";", "&", "`", "$", "<", ">"}
+
+// Runner runs allowlisted commands with resource limits. The allowlist keeps the agent
+// to the commands a repository is built and tested with. Test suites run arbitrary code
+// anyway, so what contains them is the isolation: on Linux a command gets its own user,
+// mount, PID and (unless Network is set) network namespaces, sees the file system read
+// only with the server's data and home directories replaced by empty scratch space, and
+// can only write to the directory it runs in.
+type Runner struct {
+	allowlist []rule
+	timeout   time.Duration
+	memoryMB  int
+	network   bool
+	hidden    []string
+	readOnly  []string
+}
+
+// rule is an allowlist entry: the words a command starts with and the flags it may add.
+type rule struct {
+	prefix []string
+	flags  []string
+}
+
+type Options struct {
+	// Allowlist holds commands like "go test -run -v": the words up to the first flag
+	// are what the command starts with, the flags are the only ones it may pass
+	Allowlist []string
+	Timeout   time.Duration
+	MemoryMB  int
+	// Network lets commands reach the network, they are cut off from it by default
+	Network bool
+	// Hidden directories are replaced by empty scratch space, like the workspaces of
+	// other sessions, the job store and the audit log
+	Hidden []string
+	// ReadOnly directories stay visible inside hidden ones, like the Go module cache
+	ReadOnly []string
+}
+
+type Result struct {
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Truncated  bool   `json:"truncated"`
+	TimedOut   bool   `json:"timed_out"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func NewRunner(opts Options) *Runner {
+	r := &Runner{
+		timeout:  opts.Timeout,
+		memoryMB: opts.MemoryMB,
+		network:  opts.Network,
+		// the isolated process starts in /, relative paths would point elsewhere
+		hidden:   absolute(append([]string{os.TempDir()}, opts.Hidden...)),
+		readOnly: absolute(opts.ReadOnly),
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.hidden = append(r.hidden, home)
+	}
+	for _, entry := range opts.Allowlist {
+		var allowed rule
+		for _, word := range strings.Fields(entry) {
+			if strings.HasPrefix(word, "-") {
+				allowed.flags = append(allowed.flags, word)
+			} else if len(allowed.flags) == 0 {
+				allowed.prefix = append(allowed.prefix, word)
+			}
+		}
+		if len(allowed.prefix) > 0 {
+			r.allowlist = append(r.allowlist, allowed)
+		}
+	}
+	return r
+}
+
+// Allowlist returns the allowed commands with their flags.
+func (r *Runner) Allowlist() []string {
+	entries := make([]string, 0, len(r.allowlist))
+	for _, allowed := range r.allowlist {
+		entries = append(entries, strings.Join(append(append([]string{}, allowed.prefix...), allowed.flags...), " "))
+	}
+	return entries
+}
+
+// Run executes command in dir. The command is split on whitespace into the program and
+// its arguments and never goes through a shell. timeout may shorten the configured limit
+// but not extend it. A command that runs and fails is a Result with its exit code, not
+// an error.
+func (r *Runner) Run(ctx context.Context, dir string, command string, timeout time.Duration) (Result, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return Result{}, ErrEmptyCommand
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if err := r.check(args); err != nil {
+		return Result{}, fmt.Errorf("%w: %q %v, allowed are %s", ErrCommandNotAllowed, command, err, strings.Join(r.Allowlist(), ", "))
+	}
+
+	if timeout <= 0 || timeout > r.timeout {
+		timeout = r.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := newOutput(maxOutputBytes)
+	stderr := newOutput(maxOutputBytes)
+
+	start := time.Now()
+	err = runIsolated(ctx, spec{
+		Dir:  dir,
+		Args: args,
+		Env:  environment(),
+		// CPU time a little over the wall clock limit, memory for the command and
+		// everything it starts
+		CPUSeconds:  uint64(timeout.Seconds()) + 1,
+		MemoryBytes: uint64(r.memoryMB) * 1024 * 1024,
+		Network:     r.network,
+		Hidden:      r.hidden,
+		ReadOnly:    r.readOnly,
+	}, stdout, stderr)
+	result := Result{
+		Command:    strings.Join(args, " "),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Truncated:  stdout.truncated() || stderr.truncated(),
+		TimedOut:   errors.Is(ctx.Err(), context.DeadlineExceeded),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return Result{}, fmt.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return result, nil
+}
+
+// check finds an allowlist entry the command starts with and whose flags cover every
+// flag it passes. Other arguments, like packages and paths, are free, but shell syntax
+// and variable assignments are refused.
+func (r *Runner) check(args []string) error {
+	for _, arg := range args {
+		for _, syntax := range shellSyntax {
+			if strings.Contains(arg, syntax) {
+				return fmt.Errorf("(%q is shell syntax, commands don't run in a shell)", arg)
+			}
+		}
+		if arg == "|" || arg == "||" {
+			return fmt.Errorf("(%q is shell syntax, commands don't run in a shell)", arg)
+		}
+	}
+
+	var refused string
+	for _, allowed := range r.allowlist {
+		if len(args) < len(allowed.prefix) || !slices.Equal(args[:len(allowed.prefix)], allowed.prefix) {
+			continue
+		}
+
+		refused = ""
+		for _, arg := range args[len(allowed.prefix):] {
+			name, _, _ := strings.Cut(arg, "=")
+			if strings.HasPrefix(arg, "-") && !slices.Contains(allowed.flags, name) {
+				refused = fmt.Sprintf("(flag %s is not allowed)", name)
+				break
+			}
+			if !strings.HasPrefix(arg, "-") && strings.Contains(arg, "=") {
+				refused = fmt.Sprintf("(%q looks like a variable assignment)", arg)
+				break
+			}
+		}
+		if refused == "" {
+			return nil
+		}
+	}
+	if refused != "" {
+		return errors.New(refused)
+	}
+	return errors.New("(no allowed command matches)")
+}
+
+// spec is what the isolated process needs to set itself up and start the command.
+type spec struct {
+	Dir         string   `json:"dir"`
+	Args        []string `json:"args"`
+	Env         []string `json:"env"`
+	CPUSeconds  uint64   `json:"cpu_seconds"`
+	MemoryBytes uint64   `json:"memory_bytes"`
+	Network     bool     `json:"network"`
+	Hidden      []string `json:"hidden"`
+	ReadOnly    []string `json:"read_only"`
+}
+
+func absolute(paths []string) []string {
+	var resolved []string
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			resolved = append(resolved, abs)
+		}
+	}
+	return resolved
+}
+
+func environment() []string {
+	env := []string{"CI=true", "TMPDIR=/tmp"}
+	for _, key := range passthroughEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// output keeps the start and the end of a stream, where compiler errors and test
+// summaries are, and drops the middle once it grows past max.
+type output struct {
+	max     int
+	head    []byte
+	tail    []byte
+	dropped int
+}
+
+func newOutput(max int) *output {
+	return &output{max: max}
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := o.max/2 - len(o.head); room > 0 {
+		take := min(room, len(p))
+		o.head = append(o.head, p[:take]...)
+		p = p[take:]
+	}
+
+	o.tail = append(o.tail, p...)
+	if excess := len(o.tail) - o.max/2; excess > 0 {
+		o.dropped += excess
+		o.tail = append(o.tail[:0], o.tail[excess:]...)
+	}
+	return n, nil
+}
+
+func (o *output) truncated() bool {
+	return o.dropped > 0
+}
+
+func (o *output) String() string {
+	if o.dropped == 0 {
+		return string(o.head) + string(o.tail)
+	}
+	return string(o.head) + "\n... " + strconv.Itoa(o.dropped) + " bytes omitted ...\n" + string(o.tail)
+}
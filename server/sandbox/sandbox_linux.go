@@ -0,0 +1,316 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// initName is argv[0] of the server started again as a command's isolated init step.
+const initName = "gollama-sandbox"
+
+// setupFailed is the exit code of an init step that couldn't isolate the command, which
+// then never ran.
+const setupFailed = 125
+
+// Init sets up the isolation and starts the command when the process was started as a
+// command's init step, and never returns then. Call it first thing in main, and in
+// TestMain of tests that run commands.
+func Init() {
+	if len(os.Args) != 2 || os.Args[0] != initName {
+		return
+	}
+
+	// whatever the server's packages print while initializing goes to /dev/null, the
+	// command's output goes to the pipes passed as fd 3 and 4
+	stderr := os.NewFile(4, "stderr")
+
+	var s spec
+	err := json.Unmarshal([]byte(os.Args[1]), &s)
+	if err == nil {
+		err = s.enter()
+	}
+	fmt.Fprintf(stderr, "sandbox: %v\n", err)
+	os.Exit(setupFailed)
+}
+
+// runIsolated starts the server again as the command's init step, in new user, mount and
+// PID namespaces, and in a new network namespace without network. It's root there only
+// to set up the mounts and drops every capability before it starts the command.
+func runIsolated(ctx context.Context, s spec, stdout io.Writer, stderr io.Writer) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+
+	var pipes [2]struct{ r, w *os.File }
+	for i := range pipes {
+		if pipes[i].r, pipes[i].w, err = os.Pipe(); err != nil {
+			return fmt.Errorf("failed to create pipe: %w", err)
+		}
+		defer pipes[i].r.Close()
+		defer pipes[i].w.Close()
+	}
+
+	cloneflags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID)
+	if !s.Network {
+		cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", string(encoded))
+	cmd.Args[0] = initName
+	// no .env to load and nothing of the server's environment to see
+	cmd.Dir = "/"
+	cmd.Env = []string{}
+	cmd.ExtraFiles = []*os.File{pipes[0].w, pipes[1].w}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:                    true,
+		Cloneflags:                 cloneflags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+	}
+	// the command is the init of its PID namespace, everything it started dies with it
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pipes[0].w.Close()
+	pipes[1].w.Close()
+
+	var copying sync.WaitGroup
+	errOutput := &setupError{w: stderr}
+	for i, w := range []io.Writer{stdout, errOutput} {
+		copying.Add(1)
+		go func() {
+			defer copying.Done()
+			io.Copy(w, pipes[i].r)
+		}()
+	}
+
+	err = cmd.Wait()
+	copied := make(chan struct{})
+	go func() {
+		copying.Wait()
+		close(copied)
+	}()
+	select {
+	case <-copied:
+	case <-time.After(5 * time.Second):
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == setupFailed && errOutput.message != "" {
+		return errors.New(errOutput.message)
+	}
+	return err
+}
+
+// setupError passes stderr on and keeps the init step's own error, which it writes last.
+type setupError struct {
+	w       io.Writer
+	message string
+}
+
+func (e *setupError) Write(p []byte) (int, error) {
+	if message, ok := strings.CutPrefix(strings.TrimSpace(string(p)), "sandbox: "); ok {
+		e.message = message
+	}
+	return e.w.Write(p)
+}
+
+// enter isolates the process and replaces it with the command. It only returns on error.
+func (s spec) enter() error {
+	// nothing mounted here shows up outside
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	// opened before hiding, the working directory may lie inside a hidden one
+	type exposure struct {
+		path     string
+		fd       int
+		writable bool
+	}
+	var exposed []exposure
+	expose := func(path string, writable bool) error {
+		fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if errors.Is(err, unix.ENOENT) && !writable {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		exposed = append(exposed, exposure{path: path, fd: fd, writable: writable})
+		return nil
+	}
+	if err := expose(s.Dir, true); err != nil {
+		return err
+	}
+	// a worktree's repository lives in the clone it was made from
+	if commonDir := gitCommonDir(s.Dir); commonDir != "" {
+		if err := expose(commonDir, false); err != nil {
+			return err
+		}
+	}
+	for _, path := range s.ReadOnly {
+		if err := expose(path, false); err != nil {
+			return err
+		}
+	}
+
+	hidden := slices.Clone(s.Hidden)
+	slices.SortFunc(hidden, func(a, b string) int { return len(a) - len(b) })
+	var scratch []string
+	for _, path := range hidden {
+		// already gone under a shorter hidden path
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		options := "mode=0755,size=" + strconv.FormatUint(max(s.MemoryBytes, 64<<20), 10)
+		if err := unix.Mount("tmpfs", path, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, options); err != nil {
+			return fmt.Errorf("failed to hide %s: %w", path, err)
+		}
+		scratch = append(scratch, path)
+	}
+
+	// the host's /proc would show the server's environment, GitHub token included
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %w", err)
+	}
+
+	// nested paths go last so they end up on top
+	slices.SortFunc(exposed, func(a, b exposure) int { return len(a.path) - len(b.path) })
+	for _, e := range exposed {
+		if err := os.MkdirAll(e.path, 0o755); err != nil {
+			return fmt.Errorf("failed to expose %s: %w", e.path, err)
+		}
+		source := "/proc/self/fd/" + strconv.Itoa(e.fd)
+		if err := unix.Mount(source, e.path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to expose %s: %w", e.path, err)
+		}
+		unix.Close(e.fd)
+	}
+
+	readOnly := &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}
+	if err := unix.MountSetattr(-1, "/", unix.AT_RECURSIVE, readOnly); err != nil {
+		return fmt.Errorf("failed to make the file system read-only: %w", err)
+	}
+	writable := &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY}
+	for _, path := range scratch {
+		if err := unix.MountSetattr(-1, path, 0, writable); err != nil {
+			return fmt.Errorf("failed to make %s writable: %w", path, err)
+		}
+	}
+	for _, e := range exposed {
+		if !e.writable {
+			continue
+		}
+		if err := unix.MountSetattr(-1, e.path, 0, writable); err != nil {
+			return fmt.Errorf("failed to make %s writable: %w", e.path, err)
+		}
+	}
+
+	if err := os.Chdir(s.Dir); err != nil {
+		return fmt.Errorf("failed to enter %s: %w", s.Dir, err)
+	}
+
+	program, err := lookPath(s.Args[0], s.Env)
+	if err != nil {
+		return err
+	}
+
+	if err := unix.Setrlimit(unix.RLIMIT_CPU, &unix.Rlimit{Cur: s.CPUSeconds, Max: s.CPUSeconds}); err != nil {
+		return fmt.Errorf("failed to limit CPU time: %w", err)
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_DATA, &unix.Rlimit{Cur: s.MemoryBytes, Max: s.MemoryBytes}); err != nil {
+		return fmt.Errorf("failed to limit memory: %w", err)
+	}
+
+	// root in the namespace without capabilities can't undo the mounts
+	for capability := 0; capability <= unix.CAP_LAST_CAP; capability++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("failed to drop capabilities: %w", err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	if err := unix.Dup3(3, 1, 0); err != nil {
+		return fmt.Errorf("failed to attach stdout: %w", err)
+	}
+	if err := unix.Dup3(4, 2, 0); err != nil {
+		return fmt.Errorf("failed to attach stderr: %w", err)
+	}
+	unix.CloseOnExec(3)
+	unix.CloseOnExec(4)
+
+	return syscall.Exec(program, s.Args, s.Env)
+}
+
+// lookPath finds program in the PATH of the command's environment.
+func lookPath(program string, env []string) (string, error) {
+	if strings.Contains(program, "/") {
+		return program, nil
+	}
+
+	var path string
+	for _, variable := range env {
+		if value, ok := strings.CutPrefix(variable, "PATH="); ok {
+			path = value
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, program)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in PATH", program)
+}
+
+// gitCommonDir returns the repository a worktree at dir belongs to, empty for a
+// regular clone.
+func gitCommonDir(dir string) string {
+	file, err := os.Open(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(line), "gitdir: ")
+	if !ok {
+		return ""
+	}
+
+	common, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return ""
+	}
+	commonDir := strings.TrimSpace(string(common))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
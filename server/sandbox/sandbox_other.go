@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var errNoIsolation = errors.New("commands can only be isolated on Linux, run_command and pull request checks are unavailable")
+
+// Init does nothing, commands don't run outside Linux.
+func Init() {}
+
+func runIsolated(ctx context.Context, s spec, stdout io.Writer, stderr io.Writer) error {
+	return errNoIsolation
+}
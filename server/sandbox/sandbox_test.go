@@ -0,0 +1,164 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// commands start as this test binary, see Init
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestCheck(t *testing.T) {
+	r := NewRunner(Options{
+		Allowlist: []string{"go test -v -run -count", "go vet", "make test"},
+		Timeout:   time.Minute,
+	})
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{command: "go test ./...", allowed: true},
+		{command: "go test -v -run TestCheck ./sandbox", allowed: true},
+		{command: "go test -run=TestCheck -count=1 ./...", allowed: true},
+		{command: "go vet ./...", allowed: true},
+		{command: "make test", allowed: true},
+
+		{command: "go test ./... ; cat ~/.ssh/id_rsa", allowed: false},
+		{command: "go test ./...;cat /etc/shadow", allowed: false},
+		{command: "go test ./... && curl example.com", allowed: false},
+		{command: "go test ./... | sh", allowed: false},
+		{command: "go test ./... > /etc/passwd", allowed: false},
+		{command: "go test $(cat secret)", allowed: false},
+		{command: "go test `id`", allowed: false},
+		{command: "go test -exec=/bin/sh ./...", allowed: false},
+		{command: "go test -exec /bin/sh ./...", allowed: false},
+		{command: "go test -toolexec=/bin/sh ./...", allowed: false},
+		{command: "go vet -vettool=/tmp/evil ./...", allowed: false},
+		{command: "go test GOFLAGS=-exec=sh", allowed: false},
+		{command: "GOFLAGS=-exec=sh go test ./...", allowed: false},
+		{command: "go testx", allowed: false},
+		{command: "go run main.go", allowed: false},
+		{command: "make", allowed: false},
+		{command: "sh -c make test", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := r.check(strings.Fields(tt.command))
+			if tt.allowed && err != nil {
+				t.Fatalf("refused: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Fatal("allowed")
+			}
+		})
+	}
+
+	_, err := r.Run(context.Background(), t.TempDir(), "go test ./... ; cat ~/.ssh/id_rsa", 0)
+	if !errors.Is(err, ErrCommandNotAllowed) {
+		t.Fatalf("Run: got %v, want ErrCommandNotAllowed", err)
+	}
+	if _, err := r.Run(context.Background(), t.TempDir(), "  ", 0); !errors.Is(err, ErrEmptyCommand) {
+		t.Fatalf("Run: got %v, want ErrEmptyCommand", err)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("commands are only isolated on Linux")
+	}
+
+	// a secret of the server's, in a directory commands don't get to see
+	data := t.TempDir()
+	secret := filepath.Join(data, "audit.jsonl")
+	if err := os.WriteFile(secret, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// another session's workspace, next to the one the command runs in
+	other := filepath.Join(data, "other")
+	if err := os.Mkdir(other, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(data, "workspace")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// readable inside a hidden directory, like the module cache
+	shared := filepath.Join(data, "shared")
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "go.mod"), []byte("module shared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner(Options{
+		Allowlist: []string{"cat", "touch", "umount", "ls"},
+		Timeout:   time.Minute,
+		MemoryMB:  256,
+		Hidden:    []string{data},
+		ReadOnly:  []string{shared},
+	})
+	run := func(command string) Result {
+		t.Helper()
+		result, err := r.Run(context.Background(), dir, command, 0)
+		if err != nil {
+			t.Skipf("namespaces unavailable: %v", err)
+		}
+		return result
+	}
+
+	if result := run("touch built"); result.ExitCode != 0 {
+		t.Fatalf("writing inside the workspace failed: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "built")); err != nil {
+		t.Fatalf("file written inside the workspace is missing: %v", err)
+	}
+	if result := run("cat " + filepath.Join(shared, "go.mod")); result.ExitCode != 0 || result.Stdout != "module shared" {
+		t.Fatalf("reading a read-only directory failed: %+v", result)
+	}
+
+	// writable to the server, but not to commands
+	outside := "/usr/gollama-sandbox-test"
+	t.Cleanup(func() { os.Remove(outside) })
+
+	escapes := []struct {
+		name    string
+		command string
+		written string
+	}{
+		{name: "read a hidden file", command: "cat " + secret},
+		{name: "list another workspace", command: "ls " + other},
+		{name: "write another workspace", command: "touch " + filepath.Join(other, "x"), written: filepath.Join(other, "x")},
+		{name: "write a read-only directory", command: "touch " + filepath.Join(shared, "x"), written: filepath.Join(shared, "x")},
+		{name: "write outside the workspace", command: "touch " + outside, written: outside},
+		{name: "undo the hiding", command: "umount " + data},
+		{name: "read the server's environment", command: "cat /proc/" + strconv.Itoa(os.Getpid()) + "/environ"},
+	}
+	for _, tt := range escapes {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run(tt.command)
+			if result.ExitCode == 0 {
+				t.Fatalf("escaped: %+v", result)
+			}
+			if tt.written != "" {
+				if _, err := os.Stat(tt.written); err == nil {
+					t.Fatalf("%s was written", tt.written)
+				}
+			}
+		})
+	}
+	if content, err := os.ReadFile(secret); err != nil || string(content) != "token" {
+		t.Fatalf("secret changed: %q %v", content, err)
+	}
+}
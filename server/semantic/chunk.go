@@ -0,0 +1,98 @@
+package semantic
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	chunkLines   = 60
+	chunkOverlap = 10
+	// minified and generated lines would blow a chunk past what the model embeds
+	maxChunkBytes = 4000
+	maxFileBytes  = 256 * 1024
+)
+
+// Chunk is a run of lines of a file or issue, embedded as one vector.
+type Chunk struct {
+	StartLine int
+	EndLine   int
+	Text      string
+}
+
+// directories and files whose content is generated, vendored or not prose or code
+var (
+	skippedDirs = map[string]bool{
+		"node_modules": true,
+		"vendor":       true,
+		"dist":         true,
+		"build":        true,
+		"target":       true,
+		"__pycache__":  true,
+		"testdata":     true,
+	}
+	skippedFiles = map[string]bool{
+		"go.sum":            true,
+		"package-lock.json": true,
+		"yarn.lock":         true,
+		"pnpm-lock.yaml":    true,
+		"Cargo.lock":        true,
+		"poetry.lock":       true,
+	}
+	skippedExts = map[string]bool{
+		".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".ico": true, ".svg": true,
+		".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".woff": true, ".woff2": true,
+		".ttf": true, ".mp4": true, ".mp3": true, ".wasm": true, ".exe": true, ".so": true,
+	}
+)
+
+// Indexable tells whether a file of the given path and size is worth embedding.
+func Indexable(file string, size int64) bool {
+	if size == 0 || size > maxFileBytes {
+		return false
+	}
+	name := path.Base(file)
+	if skippedFiles[name] || skippedExts[strings.ToLower(path.Ext(name))] || strings.HasSuffix(name, ".min.js") {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if skippedDirs[dir] || (strings.HasPrefix(dir, ".") && dir != ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// ChunkText splits text into overlapping runs of lines, so a match near a chunk's edge
+// still has its context. Binary content gives no chunks.
+func ChunkText(content []byte) []Chunk {
+	if bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content) {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if len(text) > maxChunkBytes {
+			// cut on a rune boundary, the embeddings API rejects invalid UTF-8
+			cut := maxChunkBytes
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut]
+		}
+		// a cut chunk ends at the last line it still has some of
+		endLine := start + strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: endLine, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,115 @@
+package semantic
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// numberedLines returns n lines reading "line 1" to "line n".
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestChunkText(t *testing.T) {
+	type span struct{ start, end int }
+
+	tests := []struct {
+		name    string
+		content string
+		spans   []span
+	}{
+		{name: "empty", content: "", spans: nil},
+		{name: "blank lines only", content: "\n  \n\t\n", spans: nil},
+		{name: "one line", content: "package main", spans: []span{{1, 1}}},
+		{name: "exactly one chunk", content: numberedLines(60), spans: []span{{1, 60}}},
+		{name: "overlapping chunks", content: numberedLines(61), spans: []span{{1, 60}, {51, 61}}},
+		{name: "three chunks", content: numberedLines(130), spans: []span{{1, 60}, {51, 110}, {101, 130}}},
+		{name: "binary", content: "PNG\x00\x01\x02", spans: nil},
+		{name: "invalid utf-8", content: "caf\xe9\n", spans: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkText([]byte(tt.content))
+			if len(chunks) != len(tt.spans) {
+				t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.spans))
+			}
+			for i, chunk := range chunks {
+				want := tt.spans[i]
+				if chunk.StartLine != want.start || chunk.EndLine != want.end {
+					t.Errorf("chunk %d covers lines %d-%d, want %d-%d", i, chunk.StartLine, chunk.EndLine, want.start, want.end)
+				}
+				if first := strings.SplitN(chunk.Text, "\n", 2)[0]; strings.HasPrefix(first, "line ") && first != fmt.Sprintf("line %d", want.start) {
+					t.Errorf("chunk %d starts with %q", i, first)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkTextCapsBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		endLine int
+	}{
+		// 301 bytes a line with the newline, the cap falls inside line 14
+		{name: "ascii", line: strings.Repeat("x", 300), endLine: 14},
+		// three bytes per rune, so the cap falls inside one
+		{name: "multi-byte", line: "a" + strings.Repeat("€", 100), endLine: 14},
+		// 400 bytes a line, the cap falls right after the newline of line 10
+		{name: "cut at a line end", line: strings.Repeat("x", 399), endLine: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkText([]byte(strings.Repeat(tt.line+"\n", 60)))
+			if len(chunks) != 1 {
+				t.Fatalf("got %d chunks, want 1", len(chunks))
+			}
+			text := chunks[0].Text
+			if len(text) > maxChunkBytes || len(text) < maxChunkBytes-utf8.UTFMax {
+				t.Errorf("chunk is %d bytes, want just under %d", len(text), maxChunkBytes)
+			}
+			if !utf8.ValidString(text) {
+				t.Error("cutting the chunk left invalid UTF-8")
+			}
+			if chunks[0].StartLine != 1 || chunks[0].EndLine != tt.endLine {
+				t.Errorf("chunk covers lines %d-%d, want 1-%d", chunks[0].StartLine, chunks[0].EndLine, tt.endLine)
+			}
+		})
+	}
+}
+
+func TestIndexable(t *testing.T) {
+	tests := []struct {
+		path string
+		size int64
+		want bool
+	}{
+		{path: "main.go", size: 100, want: true},
+		{path: "docs/guide.md", size: 100, want: true},
+		{path: "empty.go", size: 0, want: false},
+		{path: "huge.go", size: maxFileBytes + 1, want: false},
+		{path: "go.sum", size: 100, want: false},
+		{path: "web/package-lock.json", size: 100, want: false},
+		{path: "assets/logo.PNG", size: 100, want: false},
+		{path: "static/app.min.js", size: 100, want: false},
+		{path: "vendor/github.com/x/y.go", size: 100, want: false},
+		{path: "web/node_modules/react/index.js", size: 100, want: false},
+		{path: ".github/workflows/ci.yml", size: 100, want: false},
+		{path: "pkg/testdata/input.txt", size: 100, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Indexable(tt.path, tt.size); got != tt.want {
+			t.Errorf("Indexable(%q, %d) = %v, want %v", tt.path, tt.size, got, tt.want)
+		}
+	}
+}
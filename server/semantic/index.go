@@ -0,0 +1,375 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gollama/tracing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const embedBatch = 32
+
+const (
+	SourceCode   = "code"
+	SourceIssues = "issues"
+)
+
+var (
+	ErrEmptyQuery  = errors.New("query is required")
+	ErrInvalidName = errors.New("invalid owner or repository name")
+)
+
+// owner and repo name the index file, so they can't be allowed to climb out of the directory
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type Options struct {
+	// Dir holds one index file per repository
+	Dir string
+	// BaseURL is an OpenAI-compatible API serving /embeddings
+	BaseURL string
+	Model   string
+}
+
+// Indexer embeds repository files and issues and searches them by meaning rather than
+// by keyword.
+type Indexer struct {
+	dir    string
+	model  string
+	client *openai.Client
+	repos  map[string]*repoIndex
+	mu     sync.Mutex
+}
+
+// Issue is an issue as the indexer embeds it.
+type Issue struct {
+	Number    int
+	Title     string
+	Body      string
+	URL       string
+	UpdatedAt time.Time
+}
+
+// Stats tells how much of an update was embedded and how much was already indexed.
+type Stats struct {
+	Embedded int `json:"embedded"`
+	Reused   int `json:"reused"`
+	Removed  int `json:"removed"`
+}
+
+type Result struct {
+	Source    string  `json:"source"`
+	Path      string  `json:"path,omitempty"`
+	Issue     int     `json:"issue,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+}
+
+func NewIndexer(opts Options) *Indexer {
+	// token is not needed for local Ollama, but required in openai library
+	config := openai.DefaultConfig("")
+	config.BaseURL = opts.BaseURL
+	config.HTTPClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+
+	return &Indexer{
+		dir:    opts.Dir,
+		model:  opts.Model,
+		client: openai.NewClientWithConfig(config),
+		repos:  make(map[string]*repoIndex),
+	}
+}
+
+// repo returns the index of owner/repo locked, loading it from disk on first use.
+func (ix *Indexer) repo(owner string, repo string) (*repoIndex, error) {
+	if !namePattern.MatchString(owner) || !namePattern.MatchString(repo) {
+		return nil, ErrInvalidName
+	}
+	key := owner + "/" + repo
+
+	ix.mu.Lock()
+	index, exists := ix.repos[key]
+	if !exists {
+		var err error
+		index, err = loadRepoIndex(filepath.Join(ix.dir, owner, repo+".gob"), ix.model)
+		if err != nil {
+			ix.mu.Unlock()
+			return nil, err
+		}
+		ix.repos[key] = index
+	}
+	ix.mu.Unlock()
+
+	index.mu.Lock()
+	return index, nil
+}
+
+// UpdateFiles brings the code index of owner/repo to a commit checked out in dir, given
+// its blobs by path. Only blobs that weren't indexed before are read and embedded, and
+// progress is kept when embedding fails halfway.
+func (ix *Indexer) UpdateFiles(ctx context.Context, owner string, repo string, dir string, blobs map[string]string) (Stats, error) {
+	index, err := ix.repo(owner, repo)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer index.mu.Unlock()
+
+	var stats Stats
+	var pending []string
+	for path, sha := range blobs {
+		if _, ok := index.Blobs[sha]; ok {
+			stats.Reused++
+			continue
+		}
+		pending = append(pending, path)
+	}
+	sort.Strings(pending)
+
+	var embedErr error
+	for _, path := range pending {
+		sha := blobs[path]
+		// the same content may sit at several paths
+		if _, ok := index.Blobs[sha]; ok {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		doc := &Document{Version: sha, Chunks: ChunkText(content)}
+		if doc.Vectors, embedErr = ix.embedChunks(ctx, path, doc.Chunks); embedErr != nil {
+			break
+		}
+		index.Blobs[sha] = doc
+		stats.Embedded++
+	}
+
+	files := make(map[string]string, len(blobs))
+	for path, sha := range blobs {
+		if _, ok := index.Blobs[sha]; ok {
+			files[path] = sha
+		}
+	}
+	index.Files = files
+
+	// blobs no path of the commit points at anymore
+	referenced := make(map[string]bool, len(files))
+	for _, sha := range files {
+		referenced[sha] = true
+	}
+	if embedErr == nil {
+		for sha := range index.Blobs {
+			if !referenced[sha] {
+				delete(index.Blobs, sha)
+				stats.Removed++
+			}
+		}
+	}
+
+	if err := index.save(); err != nil {
+		return stats, err
+	}
+	if embedErr != nil {
+		return stats, fmt.Errorf("failed to embed files: %w", embedErr)
+	}
+	return stats, nil
+}
+
+// IssuesSince is when the most recently updated issue indexed of owner/repo was updated,
+// zero when none are.
+func (ix *Indexer) IssuesSince(owner string, repo string) (time.Time, error) {
+	index, err := ix.repo(owner, repo)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer index.mu.Unlock()
+
+	return index.IssuesSince, nil
+}
+
+// UpdateIssues embeds issues that are new or changed since they were indexed.
+func (ix *Indexer) UpdateIssues(ctx context.Context, owner string, repo string, issues []Issue) (Stats, error) {
+	index, err := ix.repo(owner, repo)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer index.mu.Unlock()
+
+	var stats Stats
+	var embedErr error
+	for _, issue := range issues {
+		version := issue.UpdatedAt.UTC().Format(time.RFC3339)
+		if doc, ok := index.Issues[issue.Number]; ok && doc.Version == version {
+			stats.Reused++
+			continue
+		}
+
+		doc := &Document{
+			Version: version,
+			Title:   issue.Title,
+			URL:     issue.URL,
+			Chunks:  ChunkText([]byte(issue.Title + "\n\n" + issue.Body)),
+		}
+		label := "issue #" + strconv.Itoa(issue.Number)
+		if doc.Vectors, embedErr = ix.embedChunks(ctx, label, doc.Chunks); embedErr != nil {
+			break
+		}
+		index.Issues[issue.Number] = doc
+		stats.Embedded++
+		if issue.UpdatedAt.After(index.IssuesSince) {
+			index.IssuesSince = issue.UpdatedAt
+		}
+	}
+
+	if err := index.save(); err != nil {
+		return stats, err
+	}
+	if embedErr != nil {
+		return stats, fmt.Errorf("failed to embed issues: %w", embedErr)
+	}
+	return stats, nil
+}
+
+// Search ranks the indexed chunks of owner/repo from the given sources by cosine
+// similarity to query.
+func (ix *Indexer) Search(ctx context.Context, owner string, repo string, query string, limit int, sources ...string) ([]Result, error) {
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	vectors, err := ix.embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	queryVector := vectors[0]
+
+	index, err := ix.repo(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer index.mu.Unlock()
+
+	var results []Result
+	for _, source := range sources {
+		switch source {
+		case SourceCode:
+			for path, sha := range index.Files {
+				doc := index.Blobs[sha]
+				for i, chunk := range doc.Chunks {
+					results = append(results, Result{
+						Source:    SourceCode,
+						Path:      path,
+						StartLine: chunk.StartLine,
+						EndLine:   chunk.EndLine,
+						Score:     dot(queryVector, doc.Vectors[i]),
+						Text:      chunk.Text,
+					})
+				}
+			}
+		case SourceIssues:
+			for number, doc := range index.Issues {
+				for i, chunk := range doc.Chunks {
+					results = append(results, Result{
+						Source:    SourceIssues,
+						Issue:     number,
+						Title:     doc.Title,
+						URL:       doc.URL,
+						StartLine: chunk.StartLine,
+						EndLine:   chunk.EndLine,
+						Score:     dot(queryVector, doc.Vectors[i]),
+						Text:      chunk.Text,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// embedChunks embeds the chunks of one document, prefixed with its name so a chunk
+// deep in a file still says where it comes from.
+func (ix *Indexer) embedChunks(ctx context.Context, name string, chunks []Chunk) ([][]float32, error) {
+	inputs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		inputs[i] = name + "\n\n" + chunk.Text
+	}
+	return ix.embed(ctx, inputs)
+}
+
+// embed returns a unit vector per input, asking for them in batches.
+func (ix *Indexer) embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += embedBatch {
+		batch := inputs[start:min(start+embedBatch, len(inputs))]
+
+		resp, err := ix.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input: batch,
+			Model: openai.EmbeddingModel(ix.model),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp.Data))
+		}
+
+		sort.Slice(resp.Data, func(i, j int) bool {
+			return resp.Data[i].Index < resp.Data[j].Index
+		})
+		for _, embedding := range resp.Data {
+			vectors = append(vectors, normalize(embedding.Embedding))
+		}
+	}
+	return vectors, nil
+}
+
+// normalize scales v to unit length, so the dot product of two vectors is their cosine
+// similarity.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func dot(a []float32, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,93 @@
+package semantic
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Document is a file's content at one blob, or an issue at one update, with a vector
+// per chunk.
+type Document struct {
+	Version string
+	Title   string
+	URL     string
+	Chunks  []Chunk
+	Vectors [][]float32
+}
+
+// repoIndex is everything indexed of one repository, stored as one file. File content is
+// kept by blob SHA, so a file that didn't change between commits isn't embedded again.
+type repoIndex struct {
+	Model string
+	// the blob SHA of each path of the last indexed commit
+	Files map[string]string
+	Blobs map[string]*Document
+	// issues by number, Version is when they were last updated
+	Issues      map[int]*Document
+	IssuesSince time.Time
+
+	path string
+	mu   sync.Mutex
+}
+
+func loadRepoIndex(path string, model string) (*repoIndex, error) {
+	index := &repoIndex{path: path}
+
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+		if err := gob.NewDecoder(file).Decode(index); err != nil {
+			return nil, fmt.Errorf("failed to read index %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read index %s: %w", path, err)
+	}
+
+	// vectors of different models can't be compared
+	if index.Model != model {
+		index.Model = model
+		index.Files = nil
+		index.Blobs = nil
+		index.Issues = nil
+		index.IssuesSince = time.Time{}
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]string)
+	}
+	if index.Blobs == nil {
+		index.Blobs = make(map[string]*Document)
+	}
+	if index.Issues == nil {
+		index.Issues = make(map[int]*Document)
+	}
+	return index, nil
+}
+
+// save writes the index, to a temp file first so a crash never leaves half of it behind.
+func (r *repoIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	tmp := r.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := gob.NewEncoder(file).Encode(r); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
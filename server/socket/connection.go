@@ -3,18 +3,63 @@ package socket
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gollama/auth"
+	"gollama/config"
+	"gollama/logging"
+	"gollama/metrics"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// time allowed to write a frame to the client
+	writeWait = 10 * time.Second
+
+	// time allowed between pongs before the client is considered gone
+	pongWait = 60 * time.Second
+
+	// must be shorter than pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// largest inbound frame, chat messages are small
+	maxMessageSize = 64 * 1024
+
+	// room for a full replay plus the live frames published while it's written
+	sendBufferSize = 2 * streamBufferSize
+)
+
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// allowing all origins for now
-		return true
-	},
+	CheckOrigin: checkOrigin,
+}
+
+// checkOrigin allows browsers only from the configured origins. Requests without an
+// Origin header come from non-browser clients, which must bring a token or cookie
+// instead, even when authentication is off.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if auth.HasCredentials(r) {
+			return true
+		}
+		slog.WarnContext(r.Context(), "Rejected WebSocket upgrade without origin or credentials", "remote_addr", r.RemoteAddr)
+		return false
+	}
+
+	for _, allowed := range config.ENV.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	slog.WarnContext(r.Context(), "Rejected WebSocket upgrade", "origin", origin, "remote_addr", r.RemoteAddr)
+	return false
 }
 
 type Connection struct {
@@ -22,14 +67,26 @@ type Connection struct {
 	send      chan []byte
 	sessionID string
 	request   *http.Request
+	following map[*Stream]bool
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
 }
 
 type Message struct {
-	Content   string `json:"content"`
-	SessionID string `json:"session_id,omitempty"`
-	Response  string `json:"response,omitempty"`
-	Error     string `json:"error,omitempty"`
-	IsProcessing  bool   `json:"is_processing,omitempty"`
+	Content      string          `json:"content"`
+	SessionID    string          `json:"session_id,omitempty"`
+	Response     string          `json:"response,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	IsProcessing bool            `json:"is_processing,omitempty"`
+	JobID        string          `json:"job_id,omitempty"`
+	JobSeq       int             `json:"job_seq,omitempty"`
+	Seq          int64           `json:"seq,omitempty"`
+	LastSeq      int64           `json:"last_seq,omitempty"`
+	Command      string          `json:"command,omitempty"`
+	Args         json.RawMessage `json:"args,omitempty"`
+	Data         any             `json:"data,omitempty"`
 }
 
 func NewConnection(c *gin.Context) (*Connection, error) {
@@ -38,51 +95,173 @@ func NewConnection(c *gin.Context) (*Connection, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(logging.With(c.Request.Context(), "remote_addr", c.Request.RemoteAddr))
+
 	conn := &Connection{
-		ws:      ws,
-		send:    make(chan []byte, 256),
-		request: c.Request,
+		ws:        ws,
+		send:      make(chan []byte, sendBufferSize),
+		request:   c.Request,
+		following: make(map[*Stream]bool),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 
+	metrics.WebSocketConnections.Inc()
+
 	return conn, nil
 }
 
+// Context is cancelled as soon as either pump stops, so anything tied to the
+// connection can shut down with it.
 func (c *Connection) Context() context.Context {
-	return c.request.Context()
+	return c.ctx
+}
+
+// Close shuts the connection down. It is safe to call more than once and from either pump.
+func (c *Connection) Close() {
+	c.closeOnce.Do(func() {
+		metrics.WebSocketConnections.Dec()
+		c.cancel()
+		c.ws.Close()
+	})
 }
 
 func (c *Connection) ReadPump(handler func(*Connection, Message)) {
-	defer c.ws.Close()
+	defer c.Close()
+
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
 
 	for {
 		var msg Message
 		err := c.ws.ReadJSON(&msg)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				slog.WarnContext(c.ctx, "WebSocket closed unexpectedly", "error", err)
 			}
 			break
 		}
-		
-		if msg.Content != "" {
+
+		if msg.Content != "" || msg.JobID != "" || msg.SessionID != "" || msg.Command != "" {
 			handler(c, msg)
 		}
 	}
 }
 
 func (c *Connection) WritePump() {
-	defer c.ws.Close()
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+	}()
 
-	for message := range c.send {
-		c.ws.WriteMessage(websocket.TextMessage, message)
+	for {
+		select {
+		case message := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
 	}
 }
 
-func (c *Connection) SendMessage(msg Message) {
+// SendMessage queues a frame for the client and reports whether it was accepted.
+// The send channel is never closed; a client that lets the buffer fill up is
+// disconnected instead, and can reconnect and replay what it missed by seq.
+func (c *Connection) SendMessage(msg Message) bool {
 	data, _ := json.Marshal(msg)
+
+	select {
+	case <-c.ctx.Done():
+		return false
+	default:
+	}
+
 	select {
 	case c.send <- data:
+		return true
+	case <-c.ctx.Done():
+		return false
 	default:
-		close(c.send)
+		slog.WarnContext(c.ctx, "WebSocket client too slow, dropping connection")
+		c.ws.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+			time.Now().Add(writeWait),
+		)
+		c.Close()
+		return false
 	}
 }
+
+// replay queues a frame the client missed, waiting for room in the send buffer.
+func (c *Connection) replay(msg Message) bool {
+	data, _ := json.Marshal(msg)
+
+	select {
+	case c.send <- data:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// Follow replays the session's frames after lastSeq and keeps forwarding new ones
+// until the connection closes. Following a stream twice is a no-op.
+func (c *Connection) Follow(stream *Stream, lastSeq int64) {
+	c.mu.Lock()
+	if c.following[stream] {
+		c.mu.Unlock()
+		return
+	}
+	c.following[stream] = true
+	c.mu.Unlock()
+
+	missed, frames, cancel := stream.Subscribe(lastSeq)
+
+	go func() {
+		defer func() {
+			cancel()
+			c.mu.Lock()
+			delete(c.following, stream)
+			c.mu.Unlock()
+		}()
+
+		// a replay waits for the writer instead of counting as a slow client, live
+		// frames queue up in the subscription meanwhile
+		for _, msg := range missed {
+			if !c.replay(msg) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case msg, ok := <-frames:
+				if !ok {
+					// dropped for falling behind; closing makes the client reconnect and
+					// replay from its last seq instead of silently missing the session
+					if !stream.Removed() {
+						slog.WarnContext(c.Context(), "WebSocket client fell behind, closing connection", "session_id", stream.sessionID)
+						c.Close()
+					}
+					return
+				}
+				c.SendMessage(msg)
+			case <-c.Context().Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package socket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gollama/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestFollowReplaysFullBacklog(t *testing.T) {
+	const origin = "http://gollama.test"
+	config.ENV.AllowedOrigins = []string{origin}
+	gin.SetMode(gin.TestMode)
+
+	stream := NewHub().Stream("session")
+	// a long run the client missed fills the whole replay window
+	const missed = streamBufferSize + 10
+	for range missed {
+		stream.Publish(Message{Response: "missed"})
+	}
+
+	const live = 20
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		conn, err := NewConnection(c)
+		if err != nil {
+			t.Errorf("NewConnection: %v", err)
+			return
+		}
+		conn.Follow(stream, 0)
+		// frames published during the replay, before the writer drains anything
+		for range live {
+			stream.Publish(Message{Response: "live"})
+		}
+		go conn.WritePump()
+		conn.ReadPump(func(*Connection, Message) {})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", http.Header{"Origin": {origin}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer ws.Close()
+
+	// the oldest frames fell out of the buffer
+	want := int64(missed - streamBufferSize + 1)
+	for received := 0; received < streamBufferSize+live; received++ {
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("connection dropped after %d of %d frames: %v", received, streamBufferSize+live, err)
+		}
+		if msg.Seq != want {
+			t.Fatalf("frame %d: got seq %d, want %d", received, msg.Seq, want)
+		}
+		want++
+	}
+}
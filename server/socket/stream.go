@@ -0,0 +1,168 @@
+package socket
+
+import (
+	"sync"
+	"time"
+)
+
+const streamBufferSize = 256
+
+// Stream is the outbound side of a session. Every frame gets the next sequence number
+// and is kept in a bounded buffer, so a client that reconnects can replay what it missed.
+type Stream struct {
+	sessionID     string
+	seq           int64
+	buffer        []Message
+	subscribers   map[chan Message]struct{}
+	lastPublished time.Time
+	// the session was deleted, so closed subscriptions aren't coming back
+	removed bool
+	mu      sync.Mutex
+}
+
+// Publish stamps the message with the session ID and the next sequence number and
+// delivers it to every attached subscriber.
+func (s *Stream) Publish(msg Message) Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+	msg.SessionID = s.sessionID
+	s.lastPublished = time.Now()
+
+	s.buffer = append(s.buffer, msg)
+	if len(s.buffer) > streamBufferSize {
+		s.buffer = s.buffer[len(s.buffer)-streamBufferSize:]
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			// too slow, the client has to reconnect and replay from its last seq
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return msg
+}
+
+func (s *Stream) Removed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removed
+}
+
+func (s *Stream) Seq() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// Subscribe returns the buffered frames after lastSeq followed by a channel of live frames.
+// The channel is closed if the subscriber falls behind. If lastSeq is ahead of the stream
+// (the server restarted), everything still buffered is replayed.
+func (s *Stream) Subscribe(lastSeq int64) ([]Message, <-chan Message, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lastSeq > s.seq {
+		lastSeq = 0
+	}
+
+	var missed []Message
+	for _, msg := range s.buffer {
+		if msg.Seq > lastSeq {
+			missed = append(missed, msg)
+		}
+	}
+
+	ch := make(chan Message, streamBufferSize)
+	s.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.subscribers[ch]; exists {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return missed, ch, cancel
+}
+
+// Hub holds the stream of every active session.
+type Hub struct {
+	streams map[string]*Stream
+	mu      sync.Mutex
+}
+
+func NewHub() *Hub {
+	h := &Hub{
+		streams: make(map[string]*Stream),
+	}
+
+	go h.cleanupStreams()
+
+	return h
+}
+
+func (h *Hub) Stream(sessionID string) *Stream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if stream, exists := h.streams[sessionID]; exists {
+		return stream
+	}
+
+	stream := &Stream{
+		sessionID:     sessionID,
+		subscribers:   make(map[chan Message]struct{}),
+		lastPublished: time.Now(),
+	}
+	h.streams[sessionID] = stream
+	return stream
+}
+
+// Remove drops a session's stream; attached subscribers are disconnected.
+func (h *Hub) Remove(sessionID string) {
+	h.mu.Lock()
+	stream, exists := h.streams[sessionID]
+	delete(h.streams, sessionID)
+	h.mu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	stream.removed = true
+	for ch := range stream.subscribers {
+		delete(stream.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *Hub) cleanupStreams() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.Lock()
+		now := time.Now()
+		for id, stream := range h.streams {
+			stream.mu.Lock()
+			// same lifetime as the chat sessions themselves
+			idle := len(stream.subscribers) == 0 && now.Sub(stream.lastPublished) > 2*time.Hour
+			stream.mu.Unlock()
+			if idle {
+				delete(h.streams, id)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// ConflictError means the file changed on the branch after the agent read it.
+type ConflictError struct {
+	Path        string
+	ExpectedSHA string
+	CurrentSHA  string
+	// Diff goes from the version the agent read to the one on the branch now
+	Diff string
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	if e.CurrentSHA == "" {
+		fmt.Fprintf(&b, "conflict: %s was deleted after you read it (expected sha %s).", e.Path, e.ExpectedSHA)
+	} else {
+		fmt.Fprintf(&b, "conflict: %s changed after you read it (expected sha %s, now %s).", e.Path, e.ExpectedSHA, e.CurrentSHA)
+	}
+	b.WriteString(" Nothing was written. Re-read the file, merge your change into the current content and retry with the new sha.")
+	if e.Diff != "" {
+		fmt.Fprintf(&b, "\n\nChanges since you read it:\n%s", e.Diff)
+	}
+	return b.String()
+}
+
+// checkExpectedSHA compares the sha the agent read with the file's current state on the
+// branch. Without a sha there is nothing to compare, so the write goes on top of whatever
+// is on the branch, as it did before the check existed. current loads the file's content
+// and is only called for the diff of a conflict.
+func checkExpectedSHA(ctx context.Context, client *github.Client, owner string, repo string, path string, expectedSHA string, exists bool, currentSHA string, current func() (string, error)) error {
+	switch {
+	case expectedSHA == "":
+		return nil
+	case !exists:
+		return &ConflictError{Path: path, ExpectedSHA: expectedSHA}
+	case expectedSHA == currentSHA:
+		return nil
+	}
+
+	conflict := &ConflictError{
+		Path:        path,
+		ExpectedSHA: expectedSHA,
+		CurrentSHA:  currentSHA,
+	}
+
+	// the blob the agent read is still in the repository even if the branch moved on
+	read, _, err := client.Git.GetBlobRaw(ctx, owner, repo, expectedSHA)
+	if err != nil {
+		return conflict
+	}
+	if content, err := current(); err == nil {
+		conflict.Diff = UnifiedDiff(path, string(read), content)
+	}
+	return conflict
+}
+
+// blobContent reads a blob by sha. Unlike the contents API it works for files over 1 MB.
+func blobContent(ctx context.Context, client *github.Client, owner string, repo string, sha string) (string, error) {
+	content, _, err := client.Git.GetBlobRaw(ctx, owner, repo, sha)
+	if err != nil {
+		return "", fmt.Errorf("failed to get blob %s: %w", sha, err)
+	}
+	return string(content), nil
+}
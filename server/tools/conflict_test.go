@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestCheckExpectedSHA(t *testing.T) {
+	// serves the blob the agent read, by sha
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/demo/git/blobs/read" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("old line\n"))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	tests := []struct {
+		name        string
+		expectedSHA string
+		exists      bool
+		conflict    bool
+		diff        bool
+	}{
+		{name: "new file without sha", exists: false},
+		{name: "existing file without sha", exists: true},
+		{name: "sha matches", expectedSHA: "current", exists: true},
+		{name: "file changed", expectedSHA: "read", exists: true, conflict: true, diff: true},
+		{name: "file deleted", expectedSHA: "read", exists: false, conflict: true},
+		{name: "read blob is gone", expectedSHA: "unknown", exists: true, conflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := false
+			current := func() (string, error) {
+				loaded = true
+				return "new line\n", nil
+			}
+
+			err := checkExpectedSHA(context.Background(), client, "acme", "demo", "a.txt", tt.expectedSHA, tt.exists, "current", current)
+
+			var conflict *ConflictError
+			if errors.As(err, &conflict) != tt.conflict {
+				t.Fatalf("got %v, want conflict %v", err, tt.conflict)
+			}
+			// the current content is only needed for the diff, big files can't be decoded
+			if loaded != tt.diff {
+				t.Errorf("loaded the current content: %v, want %v", loaded, tt.diff)
+			}
+			if tt.diff && !strings.Contains(conflict.Diff, "-old line") {
+				t.Errorf("diff %q doesn't show the change", conflict.Diff)
+			}
+		})
+	}
+}
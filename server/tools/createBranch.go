@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"gollama/config"
+	"gollama/githubapi"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/sashabaranov/go-openai"
@@ -41,6 +41,7 @@ func createGitHubBranchTool() Tool {
 				},
 			},
 		},
+		Mutating: true,
 		Execute: func(ctx context.Context, args string) (string, error) {
 			type branchArgs struct {
 				Owner        string `json:"owner"`
@@ -59,7 +60,11 @@ func createGitHubBranchTool() Tool {
 				parsedArgs.SourceBranch = "main"
 			}
 
-			client := github.NewClient(nil).WithAuthToken(config.ENV.GithubToken)
+			client := githubapi.Client()
+
+			if sim, ok := dryRun(ctx); ok {
+				return simulateCreateBranch(ctx, client, sim, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.BranchName, parsedArgs.SourceBranch)
+			}
 
 			sourceRef, _, err := client.Git.GetRef(
 				ctx,
@@ -88,6 +93,14 @@ func createGitHubBranchTool() Tool {
 				return "", fmt.Errorf("failed to create branch: %w", err)
 			}
 
+			record(RunContextFrom(ctx), Action{
+				Kind:   ActionBranchCreated,
+				Owner:  parsedArgs.Owner,
+				Repo:   parsedArgs.Repo,
+				Branch: parsedArgs.BranchName,
+				SHA:    createdRef.Object.GetSHA(),
+			})
+
 			result := map[string]any{
 				"branch_name": parsedArgs.BranchName,
 				"sha":         createdRef.Object.GetSHA(),
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"gollama/config"
+	"gollama/githubapi"
+	"strings"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/sashabaranov/go-openai"
@@ -53,6 +54,7 @@ func createGitHubPRTool() Tool {
 				},
 			},
 		},
+		Mutating: true,
 		Execute: func(ctx context.Context, args string) (string, error) {
 			type prArgs struct {
 				Owner string `json:"owner"`
@@ -70,7 +72,19 @@ func createGitHubPRTool() Tool {
 				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
 			}
 
-			client := github.NewClient(nil).WithAuthToken(config.ENV.GithubToken)
+			client := githubapi.Client()
+
+			if sim, ok := dryRun(ctx); ok {
+				return simulateCreatePR(ctx, client, sim, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Title, parsedArgs.Body, parsedArgs.Head, parsedArgs.Base, parsedArgs.Draft)
+			}
+
+			report, err := verifyPullRequest(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Head, parsedArgs.Base)
+			if err != nil {
+				return "", err
+			}
+			if report != nil {
+				parsedArgs.Body = strings.TrimRight(parsedArgs.Body, "\n") + "\n\n" + report.Markdown()
+			}
 
 			newPR := &github.NewPullRequest{
 				Title: &parsedArgs.Title,
@@ -90,6 +104,14 @@ func createGitHubPRTool() Tool {
 				return "", fmt.Errorf("failed to create pull request via GitHub API: %w", err)
 			}
 
+			record(RunContextFrom(ctx), Action{
+				Kind:     ActionPROpened,
+				Owner:    parsedArgs.Owner,
+				Repo:     parsedArgs.Repo,
+				Branch:   pr.GetHead().GetRef(),
+				PRNumber: pr.GetNumber(),
+			})
+
 			result := map[string]any{
 				"number":     pr.GetNumber(),
 				"title":      pr.GetTitle(),
@@ -102,6 +124,9 @@ func createGitHubPRTool() Tool {
 				"created_at": pr.GetCreatedAt(),
 				"body":       pr.GetBody(),
 			}
+			if report != nil {
+				result["checks"] = report
+			}
 
 			resultBytes, err := json.Marshal(result)
 			if err != nil {
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext = 3
+	// above this many line pairs the preview just reports the size change
+	maxDiffCells = 4_000_000
+)
+
+// UnifiedDiff renders the change from oldContent to newContent as a unified diff of
+// path. An empty oldContent is a new file.
+func UnifiedDiff(path string, oldContent string, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	oldLines := splitLines(oldContent)
+	newLines := splitLines(newContent)
+
+	var b strings.Builder
+	if oldContent == "" {
+		fmt.Fprintf(&b, "--- /dev/null\n+++ b/%s\n", path)
+	} else {
+		fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	}
+
+	if len(oldLines)*len(newLines) > maxDiffCells {
+		fmt.Fprintf(&b, "@@ file too large to preview: %d lines -> %d lines @@\n", len(oldLines), len(newLines))
+		return b.String()
+	}
+
+	ops := diffLines(oldLines, newLines)
+	for start := 0; start < len(ops); {
+		// find the next change and the hunk around it
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		from := max(first-diffContext, start)
+		to := first
+		for to < len(ops) {
+			if ops[to].kind != ' ' {
+				to++
+				continue
+			}
+			// a gap longer than twice the context ends the hunk
+			gap := to
+			for gap < len(ops) && ops[gap].kind == ' ' {
+				gap++
+			}
+			if gap == len(ops) || gap-to > 2*diffContext {
+				to = min(to+diffContext, len(ops))
+				break
+			}
+			to = gap
+		}
+
+		oldStart, newStart := ops[from].oldLine, ops[from].newLine
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range ops[from:to] {
+			fmt.Fprintf(&b, "%c%s\n", op.kind, op.text)
+		}
+
+		start = to
+	}
+
+	return b.String()
+}
+
+type diffOp struct {
+	kind    byte // ' ', '-' or '+'
+	text    string
+	oldLine int // 1-based line this op sits at in each file
+	newLine int
+}
+
+// diffLines is a longest common subsequence diff, fine for source files.
+func diffLines(oldLines []string, newLines []string) []diffOp {
+	n, m := len(oldLines), len(newLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && oldLines[i] == newLines[j]:
+			ops = append(ops, diffOp{' ', oldLines[i], i + 1, j + 1})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', oldLines[i], i + 1, j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', newLines[j], i + 1, j + 1})
+			j++
+		}
+	}
+	return ops
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		// an empty range points at the line before it
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// Dry runs validate the arguments and read the live repository like the real call
+// would, then answer with a result shaped like the real one instead of writing.
+
+func simulateCreateBranch(ctx context.Context, client *github.Client, sim *Simulation, owner string, repo string, branchName string, sourceBranch string) (string, error) {
+	if owner == "" || repo == "" || branchName == "" {
+		return "", errors.New("owner, repo and branch_name are required")
+	}
+
+	exists, err := branchExists(ctx, client, sim, owner, repo, branchName)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("failed to create branch: reference refs/heads/%s already exists", branchName)
+	}
+
+	sourceRef, _, err := client.Git.GetRef(ctx, owner, repo, "heads/"+sim.LiveBranch(owner, repo, sourceBranch))
+	if err != nil {
+		return "", fmt.Errorf("failed to get source branch reference: %w", err)
+	}
+
+	sim.AddBranch(owner, repo, branchName, sourceBranch)
+
+	return marshalDryRun(map[string]any{
+		"branch_name": branchName,
+		"sha":         sourceRef.Object.GetSHA(),
+		"ref":         "refs/heads/" + branchName,
+		"url":         "",
+	})
+}
+
+func simulateUpdateFile(ctx context.Context, client *github.Client, sim *Simulation, owner string, repo string, path string, content string, message string, branch string, expectedSHA string) (string, error) {
+	if owner == "" || repo == "" || path == "" || message == "" || branch == "" {
+		return "", errors.New("owner, repo, path, message and branch are required")
+	}
+
+	exists, err := branchExists(ctx, client, sim, owner, repo, branch)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("failed to update file: branch %s not found", branch)
+	}
+
+	current, currentSHA, existed, err := currentContent(ctx, client, sim, owner, repo, branch, path)
+	if err != nil {
+		return "", err
+	}
+
+	loadCurrent := func() (string, error) { return current, nil }
+	if err := checkExpectedSHA(ctx, client, owner, repo, path, expectedSHA, existed, currentSHA, loadCurrent); err != nil {
+		return "", err
+	}
+
+	original := current
+	if file, simulated := sim.File(owner, repo, branch, path); simulated {
+		original, existed = file.Original, file.Existed
+	}
+	sim.WriteFile(owner, repo, branch, path, original, existed, content)
+
+	return marshalDryRun(map[string]any{
+		"path":       path,
+		"sha":        blobSHA(content),
+		"commit_sha": "",
+		"message":    message,
+		"branch":     branch,
+		"diff":       UnifiedDiff(path, current, content),
+	})
+}
+
+func simulateCreatePR(ctx context.Context, client *github.Client, sim *Simulation, owner string, repo string, title string, body string, head string, base string, draft bool) (string, error) {
+	if owner == "" || repo == "" || title == "" || head == "" || base == "" {
+		return "", errors.New("owner, repo, title, head and base are required")
+	}
+
+	for _, branch := range []string{head, base} {
+		exists, err := branchExists(ctx, client, sim, owner, repo, branch)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", fmt.Errorf("failed to create pull request: branch %s not found", branch)
+		}
+	}
+
+	open, _, err := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + head,
+		Base:  base,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if len(open) > 0 {
+		return "", fmt.Errorf("failed to create pull request: a pull request already exists for %s:%s (#%d)", owner, head, open[0].GetNumber())
+	}
+
+	var diff strings.Builder
+	var files []string
+	simulated := sim.Files(owner, repo, head)
+	for _, file := range simulated {
+		files = append(files, file.Path)
+		diff.WriteString(UnifiedDiff(file.Path, file.Original, file.Content))
+	}
+
+	// a real branch with real commits diffs against base like GitHub would
+	if len(simulated) == 0 && !sim.HasBranch(owner, repo, head) {
+		comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to compare %s...%s: %w", base, head, err)
+		}
+		for _, file := range comparison.Files {
+			files = append(files, file.GetFilename())
+			fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n%s\n", file.GetFilename(), file.GetFilename(), file.GetPatch())
+		}
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("failed to create pull request: no commits between %s and %s", base, head)
+	}
+
+	return marshalDryRun(map[string]any{
+		"number":        0,
+		"title":         title,
+		"state":         "open",
+		"url":           "",
+		"head":          head,
+		"base":          base,
+		"draft":         draft,
+		"created_at":    time.Now(),
+		"body":          body,
+		"files_changed": files,
+		"diff":          diff.String(),
+	})
+}
+
+func branchExists(ctx context.Context, client *github.Client, sim *Simulation, owner string, repo string, branch string) (bool, error) {
+	if sim.HasBranch(owner, repo, branch) {
+		return true, nil
+	}
+
+	_, resp, err := client.Git.GetRef(ctx, owner, repo, "heads/"+branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get branch %s: %w", branch, err)
+	}
+	return true, nil
+}
+
+// currentContent is the file and its sha as the branch would have them now, simulated
+// writes included.
+func currentContent(ctx context.Context, client *github.Client, sim *Simulation, owner string, repo string, branch string, path string) (string, string, bool, error) {
+	if file, simulated := sim.File(owner, repo, branch, path); simulated {
+		return file.Content, blobSHA(file.Content), true, nil
+	}
+
+	file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{
+		Ref: sim.LiveBranch(owner, repo, branch),
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", "", false, nil
+		}
+		return "", "", false, fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	if file == nil {
+		return "", "", false, fmt.Errorf("%s is a directory", path)
+	}
+
+	// files over 1 MB come without content
+	if file.GetEncoding() == "none" {
+		content, err := blobContent(ctx, client, owner, repo, file.GetSHA())
+		if err != nil {
+			return "", "", false, err
+		}
+		return content, file.GetSHA(), true, nil
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return content, file.GetSHA(), true, nil
+}
+
+func marshalDryRun(result map[string]any) (string, error) {
+	result["dry_run"] = true
+	result["note"] = "Dry run: nothing was written to GitHub."
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal dry run result: %w", err)
+	}
+	return string(resultBytes), nil
+}
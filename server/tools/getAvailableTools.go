@@ -9,6 +9,8 @@ import (
 type Tool struct {
 	Definition openai.Tool
 	Execute func(ctx context.Context, args string) (string, error)
+	// Mutating tools change something outside the conversation, like a repository
+	Mutating bool
 }
 
 func GetAvailableTools() map[string]Tool {
@@ -18,5 +20,19 @@ func GetAvailableTools() map[string]Tool {
     tools["create_github_branch"] = createGitHubBranchTool()
     tools["get_repository_files"] = getRepositoryFilesTool()
     tools["update_github_file"] = updateGitHubFileTool()
+    tools["propose_plan"] = proposePlanTool()
+    tools["workspace_open"] = workspaceOpenTool()
+    tools["workspace_read"] = workspaceReadTool()
+    tools["workspace_search"] = workspaceSearchTool()
+    tools["workspace_write_file"] = workspaceWriteFileTool()
+    tools["workspace_edit_file"] = workspaceEditFileTool()
+    tools["workspace_commit"] = workspaceCommitTool()
+    tools["workspace_push"] = workspacePushTool()
+    tools["run_command"] = runCommandTool()
+    tools["go_list_symbols"] = goListSymbolsTool()
+    tools["go_definition"] = goDefinitionTool()
+    tools["go_references"] = goReferencesTool()
+    tools["repo_map"] = repoMapTool()
+    tools["semantic_search"] = semanticSearchTool()
     return tools
 }
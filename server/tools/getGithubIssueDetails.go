@@ -4,9 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"gollama/config"
+	"gollama/githubapi"
 
-	"github.com/google/go-github/v74/github"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -55,7 +54,7 @@ func getGitHubIssueDetailsTool() Tool {
 				return "", fmt.Errorf("failed to parse issue number: %w", err)
 			}
 
-			client := github.NewClient(nil).WithAuthToken(config.ENV.GithubToken)
+			client := githubapi.Client()
 
 			issue, _, err := client.Issues.Get(
 				ctx,
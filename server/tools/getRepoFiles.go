@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"gollama/config"
+	"gollama/githubapi"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/sashabaranov/go-openai"
@@ -55,7 +55,7 @@ func getRepositoryFilesTool() Tool {
 				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
 			}
 
-			client := github.NewClient(nil).WithAuthToken(config.ENV.GithubToken)
+			client := githubapi.Client()
 
 			opts := &github.RepositoryContentGetOptions{}
 			if parsedArgs.Ref != "" {
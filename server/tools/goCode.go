@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gollama/codeintel"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	maxGoPackages    = 300
+	maxGoDefinitions = 10
+	maxGoReferences  = 200
+)
+
+var goIndexes = codeintel.NewCache()
+
+// goCodeParameters are the parameters every Go code tool takes, plus its own.
+func goCodeParameters(extra map[string]any, required ...string) map[string]any {
+	properties := map[string]any{
+		"owner": map[string]any{
+			"type":        "string",
+			"description": "The owner or organization of the repository.",
+		},
+		"repo": map[string]any{
+			"type":        "string",
+			"description": "The name of the repository.",
+		},
+		"ref": map[string]any{
+			"type":        "string",
+			"description": "The branch, tag or commit to look at. Defaults to the workspace's working tree, uncommitted edits included.",
+		},
+	}
+	for name, property := range extra {
+		properties[name] = property
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   append([]string{"owner", "repo"}, required...),
+	}
+}
+
+// goIndex parses the Go code of ref, or of the working tree when ref is empty. Commits
+// are cached, the working tree changes under the agent's edits so it's parsed every time.
+func goIndex(ctx context.Context, owner string, repo string, ref string) (*codeintel.Index, string, error) {
+	ws, err := readWorkspace(ctx, owner, repo)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if ref == "" {
+		index, err := codeintel.Build(ws.Dir())
+		return index, "", err
+	}
+
+	dir, sha, err := ws.Worktree(ctx, ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+	index, err := goIndexes.Index(dir)
+	return index, sha, err
+}
+
+func goListSymbolsTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "go_list_symbols",
+				Description: "List the Go packages of a repository, or the symbols a package declares per file with their file:line and signature.",
+				Parameters: goCodeParameters(map[string]any{
+					"package": map[string]any{
+						"type":        "string",
+						"description": "A package's import path or directory. Without it the packages are listed.",
+					},
+					"include_unexported": map[string]any{
+						"type":        "boolean",
+						"description": "Also list unexported symbols.",
+					},
+				}),
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type listArgs struct {
+				Owner             string `json:"owner"`
+				Repo              string `json:"repo"`
+				Ref               string `json:"ref"`
+				Package           string `json:"package"`
+				IncludeUnexported bool   `json:"include_unexported"`
+			}
+
+			var parsedArgs listArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			index, sha, err := goIndex(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Ref)
+			if err != nil {
+				return "", err
+			}
+
+			result := map[string]any{
+				"ref": parsedArgs.Ref,
+				"sha": sha,
+			}
+
+			if parsedArgs.Package == "" {
+				packages := index.Packages
+				result["truncated"] = len(packages) > maxGoPackages
+				if len(packages) > maxGoPackages {
+					packages = packages[:maxGoPackages]
+				}
+				result["packages"] = packages
+			} else {
+				packages := index.Package(parsedArgs.Package)
+				if len(packages) == 0 {
+					return "", fmt.Errorf("package %s not found, list the packages without the package argument", parsedArgs.Package)
+				}
+
+				listed := make([]map[string]any, 0, len(packages))
+				for _, pkg := range packages {
+					listed = append(listed, map[string]any{
+						"import_path": pkg.ImportPath,
+						"name":        pkg.Name,
+						"dir":         pkg.Dir,
+						"files":       index.FileSymbols(pkg, !parsedArgs.IncludeUnexported),
+					})
+				}
+				result["packages"] = listed
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal symbols result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func goDefinitionTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "go_definition",
+				Description: "Find where a Go symbol is declared and return its file:line, doc and source.",
+				Parameters: goCodeParameters(map[string]any{
+					"symbol": map[string]any{
+						"type":        "string",
+						"description": "The symbol as Name, pkg.Name, Type.Method or pkg.Type.Method.",
+					},
+				}, "symbol"),
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type definitionArgs struct {
+				Owner  string `json:"owner"`
+				Repo   string `json:"repo"`
+				Ref    string `json:"ref"`
+				Symbol string `json:"symbol"`
+			}
+
+			var parsedArgs definitionArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.Symbol == "" {
+				return "", errors.New("symbol is required")
+			}
+
+			index, sha, err := goIndex(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Ref)
+			if err != nil {
+				return "", err
+			}
+
+			symbols := index.Lookup(parsedArgs.Symbol)
+			if len(symbols) == 0 {
+				return "", fmt.Errorf("no declaration of %s found", parsedArgs.Symbol)
+			}
+
+			definitions := make([]map[string]any, 0, maxGoDefinitions)
+			for _, symbol := range symbols[:min(len(symbols), maxGoDefinitions)] {
+				source, truncated := index.Source(symbol)
+				definitions = append(definitions, map[string]any{
+					"symbol":           symbol,
+					"source":           source,
+					"source_truncated": truncated,
+				})
+			}
+
+			result := map[string]any{
+				"ref":         parsedArgs.Ref,
+				"sha":         sha,
+				"definitions": definitions,
+				"truncated":   len(symbols) > maxGoDefinitions,
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal definition result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func goReferencesTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "go_references",
+				Description: "Find the uses of a Go symbol with their file:line. Method uses are matched by name, so same-named methods of other types show up too.",
+				Parameters: goCodeParameters(map[string]any{
+					"symbol": map[string]any{
+						"type":        "string",
+						"description": "The symbol as Name, pkg.Name, Type.Method or pkg.Type.Method.",
+					},
+				}, "symbol"),
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type referencesArgs struct {
+				Owner  string `json:"owner"`
+				Repo   string `json:"repo"`
+				Ref    string `json:"ref"`
+				Symbol string `json:"symbol"`
+			}
+
+			var parsedArgs referencesArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.Symbol == "" {
+				return "", errors.New("symbol is required")
+			}
+
+			index, sha, err := goIndex(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Ref)
+			if err != nil {
+				return "", err
+			}
+
+			symbols := index.Lookup(parsedArgs.Symbol)
+			if len(symbols) == 0 {
+				return "", fmt.Errorf("no declaration of %s found", parsedArgs.Symbol)
+			}
+
+			// methods of one name are all found by the same search
+			symbol := symbols[0]
+			references, truncated := index.References(symbol, maxGoReferences)
+			if references == nil {
+				references = []codeintel.Reference{}
+			}
+
+			result := map[string]any{
+				"ref":           parsedArgs.Ref,
+				"sha":           sha,
+				"definition":    symbol.Location,
+				"references":    references,
+				"truncated":     truncated,
+				"ambiguous":     len(symbols) > 1,
+				"other_matches": len(symbols) - 1,
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal references result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ActionBranchCreated = "branch_created"
+	ActionFileCommitted = "file_committed"
+	ActionPROpened      = "pr_opened"
+	ActionBranchPushed  = "branch_pushed"
+)
+
+// Action is one side effect a tool had on GitHub, with what's needed to revert it.
+type Action struct {
+	// ID is the action's position in the journal, starting at 1
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	RunID     string    `json:"run_id"`
+	Owner     string    `json:"owner"`
+	Repo      string    `json:"repo"`
+	Branch    string    `json:"branch,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	SHA       string    `json:"sha,omitempty"`
+	BeforeSHA string    `json:"before_sha,omitempty"`
+	AfterSHA  string    `json:"after_sha,omitempty"`
+	PRNumber  int       `json:"pr_number,omitempty"`
+	Undone    bool      `json:"undone"`
+	Time      time.Time `json:"time"`
+}
+
+// Journal is a session's record of every side effect, grouped by the run that caused it.
+type Journal struct {
+	actions []Action
+	mu      sync.Mutex
+}
+
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+func (j *Journal) Record(action Action) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	action.ID = len(j.actions) + 1
+	action.Time = time.Now()
+	j.actions = append(j.actions, action)
+}
+
+func (j *Journal) Actions() []Action {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Action{}, j.actions...)
+}
+
+// LastRun returns the actions of the most recent run that hasn't been undone yet.
+func (j *Journal) LastRun() (string, []Action) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := len(j.actions) - 1; i >= 0; i-- {
+		if j.actions[i].Undone {
+			continue
+		}
+
+		runID := j.actions[i].RunID
+		var actions []Action
+		for _, action := range j.actions {
+			if action.RunID == runID && !action.Undone {
+				actions = append(actions, action)
+			}
+		}
+		return runID, actions
+	}
+
+	return "", nil
+}
+
+// MarkUndone flags the actions an undo reverted. Once a run has none left, the next undo
+// goes one run further back; the ones that were skipped are tried again first.
+func (j *Journal) MarkUndone(ids []int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, id := range ids {
+		if id >= 1 && id <= len(j.actions) {
+			j.actions[id-1].Undone = true
+		}
+	}
+}
+
+// record adds an action to the run's journal, if the run keeps one.
+func record(run RunContext, action Action) {
+	if run.Journal == nil {
+		return
+	}
+	action.RunID = run.RunID
+	run.Journal.Record(action)
+}
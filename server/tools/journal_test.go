@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJournalMarkUndone(t *testing.T) {
+	journal := NewJournal()
+	journal.Record(Action{Kind: ActionBranchCreated, RunID: "run-1", Branch: "a"})
+	journal.Record(Action{Kind: ActionBranchCreated, RunID: "run-2", Branch: "b"})
+	journal.Record(Action{Kind: ActionFileCommitted, RunID: "run-2", Branch: "b"})
+	journal.Record(Action{Kind: ActionPROpened, RunID: "run-2", PRNumber: 1})
+
+	tests := []struct {
+		name    string
+		undone  []int
+		runID   string
+		pending []int
+	}{
+		{name: "nothing undone yet", runID: "run-2", pending: []int{2, 3, 4}},
+		{name: "skipped actions stay in the run", undone: []int{2, 3}, runID: "run-2", pending: []int{4}},
+		{name: "unknown ids are ignored", undone: []int{0, 9}, runID: "run-2", pending: []int{4}},
+		{name: "a fully undone run moves undo back", undone: []int{4}, runID: "run-1", pending: []int{1}},
+		{name: "everything undone", undone: []int{1}, runID: "", pending: nil},
+	}
+
+	for _, tt := range tests {
+		journal.MarkUndone(tt.undone)
+
+		runID, actions := journal.LastRun()
+		var pending []int
+		for _, action := range actions {
+			pending = append(pending, action.ID)
+		}
+		if runID != tt.runID || !slices.Equal(pending, tt.pending) {
+			t.Fatalf("%s: LastRun = %q %v, want %q %v", tt.name, runID, pending, tt.runID, tt.pending)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"gollama/plan"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func proposePlanTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "propose_plan",
+				Description: "Record a structured implementation plan for the user to approve before changing a repository. Each executed tool call is checked off against the plan's steps.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"summary": map[string]any{
+							"type":        "string",
+							"description": "One or two sentences on what the plan achieves.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The repository the plan changes, as owner/repo.",
+						},
+						"branch": map[string]any{
+							"type":        "string",
+							"description": "The branch the changes will be committed to.",
+						},
+						"steps": map[string]any{
+							"type":        "array",
+							"description": "The steps in execution order.",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"description": map[string]any{
+										"type":        "string",
+										"description": "What this step does.",
+									},
+									"tool": map[string]any{
+										"type":        "string",
+										"description": "The tool this step will call, e.g. update_github_file.",
+									},
+									"files": map[string]any{
+										"type":        "array",
+										"items":       map[string]any{"type": "string"},
+										"description": "The file paths this step reads or changes.",
+									},
+									"branch": map[string]any{
+										"type":        "string",
+										"description": "The branch for this step if it differs from the plan's branch.",
+									},
+									"commit_message": map[string]any{
+										"type":        "string",
+										"description": "The commit message, for steps that commit.",
+									},
+								},
+								"required": []string{"description", "tool"},
+							},
+						},
+					},
+					"required": []string{"summary", "steps"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			// the server stores the plan on the session, this only checks it's usable
+			proposed, err := plan.Parse(args)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf(
+				"Plan recorded with %d step(s). Present it to the user and wait for their approval before executing it.",
+				len(proposed.Steps),
+			), nil
+		},
+	}
+}
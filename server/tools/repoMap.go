@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gollama/repomap"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var repoMaps = repomap.NewCache()
+
+// RepoMap outlines owner/repo at ref, the default branch when ref is empty. It clones
+// the repository into the session's workspace if it isn't there yet.
+func RepoMap(ctx context.Context, owner string, repo string, ref string) (*repomap.Map, error) {
+	ws, err := readWorkspace(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ref
+	if ref == "" {
+		// origin/HEAD is the remote's default branch
+		ref, name = "HEAD", "the default branch"
+	}
+
+	dir, sha, err := ws.Worktree(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check out %s: %w", name, err)
+	}
+	return repoMaps.Get(dir, owner, repo, name, sha)
+}
+
+func repoMapTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "repo_map",
+				Description: "Get a compact outline of a repository: what its README says it is, the directory tree, key build files and the top-level symbols of each source file.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"ref": map[string]any{
+							"type":        "string",
+							"description": "The branch, tag or commit to outline (defaults to the default branch).",
+						},
+					},
+					"required": []string{"owner", "repo"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type mapArgs struct {
+				Owner string `json:"owner"`
+				Repo  string `json:"repo"`
+				Ref   string `json:"ref"`
+			}
+
+			var parsedArgs mapArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			m, err := RepoMap(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Ref)
+			if err != nil {
+				return "", err
+			}
+
+			resultBytes, err := json.Marshal(m)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal repo map: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
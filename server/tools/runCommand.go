@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gollama/config"
+	"gollama/sandbox"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var commandRunner = sandbox.NewRunner(sandbox.Options{
+	Allowlist: config.ENV.CommandAllowlist,
+	Timeout:   time.Duration(config.ENV.CommandTimeout) * time.Second,
+	MemoryMB:  config.ENV.CommandMemoryMB,
+	Network:   config.ENV.CommandNetwork,
+	Hidden:    serverDirs(),
+	ReadOnly:  []string{goModCache()},
+})
+
+// serverDirs are where the server keeps its data, with .env in the working directory,
+// none of which a command gets to see.
+func serverDirs() []string {
+	dirs := []string{
+		config.ENV.WorkspacesDir,
+		config.ENV.JobsDir,
+		config.ENV.IndexDir,
+		config.ENV.PromptsDir,
+		filepath.Dir(config.ENV.AuditLog),
+	}
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	return dirs
+}
+
+// goModCache stays readable so Go builds work offline even with the home directory
+// hidden.
+func goModCache() string {
+	if cache := os.Getenv("GOMODCACHE"); cache != "" {
+		return cache
+	}
+	if gopath := filepath.SplitList(os.Getenv("GOPATH")); len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "go", "pkg", "mod")
+}
+
+func runCommandTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name: "run_command",
+				Description: fmt.Sprintf(
+					"Run a build, test or lint command in the local workspace and get its exit code and output. Use it to check changes before opening a pull request. The command runs isolated: it can only write inside the workspace, and the network is off unless the server allows it. Allowed commands, with the only flags they may pass: %s.",
+					joinAllowlist(commandRunner.Allowlist()),
+				),
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"command": map[string]any{
+							"type":        "string",
+							"description": "The command with its arguments, for example 'go test ./...'. It doesn't go through a shell, so pipes, redirects, ';' and variables are refused.",
+						},
+						"timeout_seconds": map[string]any{
+							"type":        "integer",
+							"description": "Stop the command after this many seconds (capped by the server's limit).",
+						},
+					},
+					"required": []string{"owner", "repo", "command"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type commandArgs struct {
+				Owner          string `json:"owner"`
+				Repo           string `json:"repo"`
+				Command        string `json:"command"`
+				TimeoutSeconds int    `json:"timeout_seconds"`
+			}
+
+			var parsedArgs commandArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			result, err := commandRunner.Run(ctx, ws.Dir(), parsedArgs.Command, time.Duration(parsedArgs.TimeoutSeconds)*time.Second)
+			if err != nil {
+				return "", err
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal command result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func joinAllowlist(prefixes []string) string {
+	quoted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		quoted[i] = "'" + prefix + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,40 @@
+package tools
+
+import "context"
+
+type runContextKey struct{}
+
+// RunContext describes the agent run a tool executes in. Tools called outside a
+// session, like from the OpenAI-compatible API, only get the run, user and prompt.
+type RunContext struct {
+	SessionID string
+	RunID     string
+	User      string
+	// Prompt is the user message that started the run
+	Prompt string
+	// DryRun makes mutating tools simulate their writes against Simulation
+	DryRun     bool
+	Simulation *Simulation
+	// Journal records the side effects of live runs so they can be undone
+	Journal *Journal
+	// Verification limits the repair attempts when checks fail before a pull request
+	Verification *Verification
+}
+
+func WithRunContext(ctx context.Context, run RunContext) context.Context {
+	return context.WithValue(ctx, runContextKey{}, run)
+}
+
+func RunContextFrom(ctx context.Context) RunContext {
+	run, _ := ctx.Value(runContextKey{}).(RunContext)
+	return run
+}
+
+// dryRun returns the run's simulation when the run is a dry run.
+func dryRun(ctx context.Context) (*Simulation, bool) {
+	run := RunContextFrom(ctx)
+	if !run.DryRun || run.Simulation == nil {
+		return nil, false
+	}
+	return run.Simulation, true
+}
@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gollama/config"
+	"gollama/githubapi"
+	"gollama/semantic"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultSemanticResults = 8
+	maxSemanticResults     = 30
+	// issues fetched per search, the rest come in on later searches
+	maxIndexedIssues = 500
+)
+
+var semanticIndex = semantic.NewIndexer(semantic.Options{
+	Dir:     config.ENV.IndexDir,
+	BaseURL: config.ENV.BaseURL,
+	Model:   config.ENV.EmbeddingModel,
+})
+
+// indexCode brings the code index of owner/repo up to ref, the default branch when
+// empty, and returns the commit it's at.
+func indexCode(ctx context.Context, owner string, repo string, ref string) (string, semantic.Stats, error) {
+	ws, err := readWorkspace(ctx, owner, repo)
+	if err != nil {
+		return "", semantic.Stats{}, err
+	}
+
+	name := ref
+	if ref == "" {
+		// origin/HEAD is the remote's default branch
+		ref, name = "HEAD", "the default branch"
+	}
+
+	dir, sha, err := ws.Worktree(ctx, ref)
+	if err != nil {
+		return "", semantic.Stats{}, fmt.Errorf("failed to check out %s: %w", name, err)
+	}
+
+	blobs, err := ws.Blobs(ctx, sha)
+	if err != nil {
+		return "", semantic.Stats{}, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	indexable := make(map[string]string, len(blobs))
+	for path, blob := range blobs {
+		if semantic.Indexable(path, blob.Size) {
+			indexable[path] = blob.SHA
+		}
+	}
+
+	stats, err := semanticIndex.UpdateFiles(ctx, owner, repo, dir, indexable)
+	return sha, stats, err
+}
+
+// indexIssues embeds the issues of owner/repo updated since the last search.
+func indexIssues(ctx context.Context, owner string, repo string) (semantic.Stats, error) {
+	since, err := semanticIndex.IssuesSince(owner, repo)
+	if err != nil {
+		return semantic.Stats{}, err
+	}
+
+	client := githubapi.Client()
+
+	opts := &github.IssueListByRepoOptions{
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "asc",
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var issues []semantic.Issue
+	for len(issues) < maxIndexedIssues {
+		page, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return semantic.Stats{}, fmt.Errorf("failed to list issues from GitHub API: %w", err)
+		}
+
+		for _, issue := range page {
+			// pull requests come back as issues too
+			if issue.IsPullRequest() {
+				continue
+			}
+			issues = append(issues, semantic.Issue{
+				Number:    issue.GetNumber(),
+				Title:     issue.GetTitle(),
+				Body:      issue.GetBody(),
+				URL:       issue.GetHTMLURL(),
+				UpdatedAt: issue.GetUpdatedAt().Time,
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return semanticIndex.UpdateIssues(ctx, owner, repo, issues)
+}
+
+func semanticSearchTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "semantic_search",
+				Description: "Search a repository's files and issues by meaning, for questions like \"where do we handle retries\" that keyword search misses. Returns the best matching chunks with their path and line range, or issue number.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"query": map[string]any{
+							"type":        "string",
+							"description": "What you're looking for, in plain words.",
+						},
+						"ref": map[string]any{
+							"type":        "string",
+							"description": "The branch, tag or commit whose files to search (defaults to the default branch).",
+						},
+						"sources": map[string]any{
+							"type":        "string",
+							"enum":        []string{"all", semantic.SourceCode, semantic.SourceIssues},
+							"description": "Search files, issues or both (defaults to both).",
+						},
+						"limit": map[string]any{
+							"type":        "integer",
+							"description": fmt.Sprintf("How many chunks to return (defaults to %d, at most %d).", defaultSemanticResults, maxSemanticResults),
+						},
+					},
+					"required": []string{"owner", "repo", "query"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type searchArgs struct {
+				Owner   string `json:"owner"`
+				Repo    string `json:"repo"`
+				Query   string `json:"query"`
+				Ref     string `json:"ref"`
+				Sources string `json:"sources"`
+				Limit   int    `json:"limit"`
+			}
+
+			var parsedArgs searchArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.Query == "" {
+				return "", semantic.ErrEmptyQuery
+			}
+
+			limit := parsedArgs.Limit
+			if limit <= 0 {
+				limit = defaultSemanticResults
+			}
+			limit = min(limit, maxSemanticResults)
+
+			var sources []string
+			switch parsedArgs.Sources {
+			case "", "all":
+				sources = []string{semantic.SourceCode, semantic.SourceIssues}
+			case semantic.SourceCode, semantic.SourceIssues:
+				sources = []string{parsedArgs.Sources}
+			default:
+				return "", fmt.Errorf("unknown sources %q, use all, code or issues", parsedArgs.Sources)
+			}
+
+			result := map[string]any{
+				"ref": parsedArgs.Ref,
+			}
+
+			start := time.Now()
+			for _, source := range sources {
+				switch source {
+				case semantic.SourceCode:
+					sha, stats, err := indexCode(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Ref)
+					if err != nil {
+						return "", err
+					}
+					result["sha"] = sha
+					result["code_index"] = stats
+				case semantic.SourceIssues:
+					// issues are a bonus, what's indexed already is still searched
+					stats, err := indexIssues(ctx, parsedArgs.Owner, parsedArgs.Repo)
+					if err != nil {
+						slog.WarnContext(ctx, "Failed to index issues", "repo", parsedArgs.Owner+"/"+parsedArgs.Repo, "error", err)
+						result["issues_error"] = err.Error()
+					}
+					result["issue_index"] = stats
+				}
+			}
+			slog.InfoContext(ctx, "Indexed repository for semantic search", "repo", parsedArgs.Owner+"/"+parsedArgs.Repo, "duration_ms", time.Since(start).Milliseconds())
+
+			results, err := semanticIndex.Search(ctx, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Query, limit, sources...)
+			if err != nil {
+				return "", err
+			}
+			if results == nil {
+				results = []semantic.Result{}
+			}
+			result["results"] = results
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal search result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Simulation remembers what a dry run pretended to write, so later calls in the
+// session see the simulated branches and files on top of the live repository.
+type Simulation struct {
+	branches map[string]string
+	files    map[string]*SimulatedFile
+	mu       sync.Mutex
+}
+
+// SimulatedFile is a file a dry run wrote. Original is the content before the first
+// simulated write, empty for a new file.
+type SimulatedFile struct {
+	Repo     string `json:"repo"`
+	Branch   string `json:"branch"`
+	Path     string `json:"path"`
+	Original string `json:"-"`
+	Content  string `json:"-"`
+	Existed  bool   `json:"existed"`
+}
+
+func NewSimulation() *Simulation {
+	return &Simulation{
+		branches: make(map[string]string),
+		files:    make(map[string]*SimulatedFile),
+	}
+}
+
+func (s *Simulation) AddBranch(owner string, repo string, branch string, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.branches[branchKey(owner, repo, branch)] = source
+}
+
+func (s *Simulation) HasBranch(owner string, repo string, branch string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.branches[branchKey(owner, repo, branch)]
+	return exists
+}
+
+// LiveBranch follows simulated branches back to the real branch they were created from.
+func (s *Simulation) LiveBranch(owner string, repo string, branch string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range len(s.branches) + 1 {
+		source, exists := s.branches[branchKey(owner, repo, branch)]
+		if !exists {
+			return branch
+		}
+		branch = source
+	}
+	return branch
+}
+
+// File returns the simulated content of a file, following simulated branches back to
+// the branch they were created from.
+func (s *Simulation) File(owner string, repo string, branch string, path string) (*SimulatedFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range len(s.branches) + 1 {
+		if file, exists := s.files[fileKey(owner, repo, branch, path)]; exists {
+			copied := *file
+			return &copied, true
+		}
+		source, exists := s.branches[branchKey(owner, repo, branch)]
+		if !exists {
+			break
+		}
+		branch = source
+	}
+	return nil, false
+}
+
+// WriteFile records a simulated write. original is the live content, used on first write.
+func (s *Simulation) WriteFile(owner string, repo string, branch string, path string, original string, existed bool, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fileKey(owner, repo, branch, path)
+	if file, exists := s.files[key]; exists {
+		file.Content = content
+		return
+	}
+	s.files[key] = &SimulatedFile{
+		Repo:     owner + "/" + repo,
+		Branch:   branch,
+		Path:     path,
+		Original: original,
+		Content:  content,
+		Existed:  existed,
+	}
+}
+
+// Files lists the files simulated on a branch, by path.
+func (s *Simulation) Files(owner string, repo string, branch string) []SimulatedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []SimulatedFile
+	for _, file := range s.files {
+		if file.Repo == owner+"/"+repo && file.Branch == branch {
+			files = append(files, *file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+func branchKey(owner string, repo string, branch string) string {
+	return fmt.Sprintf("%s/%s:%s", owner, repo, branch)
+}
+
+func fileKey(owner string, repo string, branch string, path string) string {
+	return fmt.Sprintf("%s/%s:%s:%s", owner, repo, branch, path)
+}
+
+// blobSHA is the SHA git would give the content, so simulated results look real.
+func blobSHA(content string) string {
+	hash := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(hash[:])
+}
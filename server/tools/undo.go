@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"gollama/githubapi"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// UndoReport lists what an undo reverted and what it left alone because reverting it
+// could throw away someone else's work.
+type UndoReport struct {
+	RunID    string   `json:"run_id"`
+	Reverted []string `json:"reverted"`
+	Skipped  []string `json:"skipped"`
+	// Undone holds the journal IDs of the actions that are reverted now
+	Undone []int `json:"-"`
+}
+
+// Undo reverts one run's side effects, newest first: pull requests it opened are closed,
+// branches it created are deleted, and branches it committed to are reset to where they
+// were before. Pushes from a local workspace are reverted the same way through git. A
+// branch is only deleted or reset while its head is still the agent's last commit, so
+// nothing pushed since is lost.
+func Undo(ctx context.Context, runID string, actions []Action) UndoReport {
+	report := UndoReport{
+		RunID:    runID,
+		Reverted: make([]string, 0),
+		Skipped:  make([]string, 0),
+	}
+	client := githubapi.Client()
+
+	type branchChange struct {
+		owner, repo, branch string
+		created             bool
+		createdSHA          string
+		firstBefore         string
+		lastAfter           string
+		commits             int
+		ids                 []int
+	}
+	var order []string
+	branches := make(map[string]*branchChange)
+	var pushOrder []string
+	pushes := make(map[string]*branchChange)
+	change := func(action Action) *branchChange {
+		key := branchKey(action.Owner, action.Repo, action.Branch)
+		if _, exists := branches[key]; !exists {
+			branches[key] = &branchChange{owner: action.Owner, repo: action.Repo, branch: action.Branch}
+			order = append(order, key)
+		}
+		return branches[key]
+	}
+
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+		switch action.Kind {
+		case ActionPROpened:
+			message, reverted := closePR(ctx, client, action)
+			report.add(message, reverted, action.ID)
+
+		case ActionBranchCreated:
+			c := change(action)
+			c.ids = append(c.ids, action.ID)
+			c.created = true
+			c.createdSHA = action.SHA
+
+		case ActionFileCommitted:
+			// walking backwards, so the first commit seen is the newest
+			c := change(action)
+			if c.lastAfter == "" {
+				c.lastAfter = action.AfterSHA
+			}
+			c.ids = append(c.ids, action.ID)
+			c.firstBefore = action.BeforeSHA
+			c.commits++
+
+		case ActionBranchPushed:
+			key := branchKey(action.Owner, action.Repo, action.Branch)
+			p, exists := pushes[key]
+			if !exists {
+				p = &branchChange{owner: action.Owner, repo: action.Repo, branch: action.Branch, lastAfter: action.AfterSHA}
+				pushes[key] = p
+				pushOrder = append(pushOrder, key)
+			}
+			p.ids = append(p.ids, action.ID)
+			p.firstBefore = action.BeforeSHA
+		}
+	}
+
+	for _, key := range pushOrder {
+		p := pushes[key]
+		message, reverted := undoPush(ctx, p.owner, p.repo, p.branch, p.firstBefore, p.lastAfter)
+		report.add(message, reverted, p.ids...)
+	}
+
+	for _, key := range order {
+		c := branches[key]
+		name := fmt.Sprintf("%s/%s:%s", c.owner, c.repo, c.branch)
+
+		expected := c.lastAfter
+		if expected == "" {
+			expected = c.createdSHA
+		}
+
+		ref, resp, err := client.Git.GetRef(ctx, c.owner, c.repo, "heads/"+c.branch)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				if c.created {
+					report.add(fmt.Sprintf("Branch %s was already deleted", name), true, c.ids...)
+				} else {
+					report.Skipped = append(report.Skipped, fmt.Sprintf("Branch %s no longer exists, nothing to reset", name))
+				}
+				continue
+			}
+			report.Skipped = append(report.Skipped, fmt.Sprintf("Couldn't read branch %s: %v", name, err))
+			continue
+		}
+
+		head := ref.Object.GetSHA()
+		if head != expected {
+			report.Skipped = append(report.Skipped, fmt.Sprintf(
+				"Branch %s has moved to %s since the agent's last commit %s, left it alone",
+				name, shortSHA(head), shortSHA(expected),
+			))
+			continue
+		}
+
+		if c.created {
+			if _, err := client.Git.DeleteRef(ctx, c.owner, c.repo, "heads/"+c.branch); err != nil {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("Couldn't delete branch %s: %v", name, err))
+				continue
+			}
+			report.add(fmt.Sprintf("Deleted branch %s", name), true, c.ids...)
+			continue
+		}
+
+		if c.firstBefore == "" {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("Don't know where branch %s was before the agent's commits, left it alone", name))
+			continue
+		}
+
+		// the head is the agent's own last commit, so moving back only drops the agent's commits
+		_, _, err = client.Git.UpdateRef(ctx, c.owner, c.repo, &github.Reference{
+			Ref:    github.Ptr("refs/heads/" + c.branch),
+			Object: &github.GitObject{SHA: github.Ptr(c.firstBefore)},
+		}, true)
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("Couldn't reset branch %s: %v", name, err))
+			continue
+		}
+		report.add(fmt.Sprintf(
+			"Reset branch %s from %s to %s, dropping %d commit(s)",
+			name, shortSHA(head), shortSHA(c.firstBefore), c.commits,
+		), true, c.ids...)
+	}
+
+	return report
+}
+
+func closePR(ctx context.Context, client *github.Client, action Action) (string, bool) {
+	name := fmt.Sprintf("%s/%s#%d", action.Owner, action.Repo, action.PRNumber)
+
+	pr, _, err := client.PullRequests.Get(ctx, action.Owner, action.Repo, action.PRNumber)
+	if err != nil {
+		return fmt.Sprintf("Couldn't read pull request %s: %v", name, err), false
+	}
+	if pr.GetMerged() {
+		return fmt.Sprintf("Pull request %s was already merged, revert it on GitHub", name), false
+	}
+	if pr.GetState() == "closed" {
+		return fmt.Sprintf("Pull request %s was already closed", name), true
+	}
+
+	_, _, err = client.PullRequests.Edit(ctx, action.Owner, action.Repo, action.PRNumber, &github.PullRequest{
+		State: github.Ptr("closed"),
+	})
+	if err != nil {
+		return fmt.Sprintf("Couldn't close pull request %s: %v", name, err), false
+	}
+	return fmt.Sprintf("Closed pull request %s", name), true
+}
+
+// add reports message, marking the actions ids as undone when they were reverted.
+func (r *UndoReport) add(message string, reverted bool, ids ...int) {
+	if reverted {
+		r.Reverted = append(r.Reverted, message)
+		r.Undone = append(r.Undone, ids...)
+	} else {
+		r.Skipped = append(r.Skipped, message)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -3,8 +3,10 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"gollama/config"
+	"gollama/githubapi"
+	"net/http"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/sashabaranov/go-openai"
@@ -44,19 +46,25 @@ func updateGitHubFileTool() Tool {
 							"type":        "string",
 							"description": "The branch to commit to.",
 						},
+						"expected_sha": map[string]any{
+							"type":        "string",
+							"description": "The sha get_repository_files returned when you read the file. Pass it when updating an existing file so a change made since you read it is reported instead of overwritten; omit it for a new file.",
+						},
 					},
 					"required": []string{"owner", "repo", "path", "content", "message", "branch"},
 				},
 			},
 		},
+		Mutating: true,
 		Execute: func(ctx context.Context, args string) (string, error) {
 			type fileArgs struct {
-				Owner   string `json:"owner"`
-				Repo    string `json:"repo"`
-				Path    string `json:"path"`
-				Content string `json:"content"`
-				Message string `json:"message"`
-				Branch  string `json:"branch"`
+				Owner       string `json:"owner"`
+				Repo        string `json:"repo"`
+				Path        string `json:"path"`
+				Content     string `json:"content"`
+				Message     string `json:"message"`
+				Branch      string `json:"branch"`
+				ExpectedSHA string `json:"expected_sha"`
 			}
 
 			var parsedArgs fileArgs
@@ -65,7 +73,11 @@ func updateGitHubFileTool() Tool {
 				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
 			}
 
-			client := github.NewClient(nil).WithAuthToken(config.ENV.GithubToken)
+			client := githubapi.Client()
+
+			if sim, ok := dryRun(ctx); ok {
+				return simulateUpdateFile(ctx, client, sim, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Path, parsedArgs.Content, parsedArgs.Message, parsedArgs.Branch, parsedArgs.ExpectedSHA)
+			}
 
 			opts := &github.RepositoryContentFileOptions{
 				Message: &parsedArgs.Message,
@@ -73,14 +85,32 @@ func updateGitHubFileTool() Tool {
 				Branch:  &parsedArgs.Branch,
 			}
 
-			existingFile, _, _, err := client.Repositories.GetContents(
+			existingFile, _, resp, err := client.Repositories.GetContents(
 				ctx,
 				parsedArgs.Owner,
 				parsedArgs.Repo,
 				parsedArgs.Path,
 				&github.RepositoryContentGetOptions{Ref: parsedArgs.Branch},
 			)
-			if err == nil && existingFile != nil {
+			if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+				return "", fmt.Errorf("failed to read current file: %w", err)
+			}
+			if err == nil && existingFile == nil {
+				return "", fmt.Errorf("%s is a directory", parsedArgs.Path)
+			}
+
+			exists := err == nil
+			currentContent := func() (string, error) {
+				return blobContent(ctx, client, parsedArgs.Owner, parsedArgs.Repo, existingFile.GetSHA())
+			}
+
+			err = checkExpectedSHA(ctx, client, parsedArgs.Owner, parsedArgs.Repo, parsedArgs.Path, parsedArgs.ExpectedSHA, exists, existingFile.GetSHA(), currentContent)
+			if err != nil {
+				return "", err
+			}
+
+			// GitHub refuses the write if the file moved on between our read and now
+			if exists {
 				opts.SHA = existingFile.SHA
 			}
 
@@ -91,10 +121,28 @@ func updateGitHubFileTool() Tool {
 				parsedArgs.Path,
 				opts,
 			)
+			var errorResponse *github.ErrorResponse
+			if errors.As(err, &errorResponse) && errorResponse.Response != nil && errorResponse.Response.StatusCode == http.StatusConflict {
+				return "", fmt.Errorf("conflict: %s changed on %s while it was being written. Nothing was written. Re-read the file and retry with the new sha", parsedArgs.Path, parsedArgs.Branch)
+			}
 			if err != nil {
 				return "", fmt.Errorf("failed to update file: %w", err)
 			}
 
+			var beforeSHA string
+			if parents := fileResponse.Commit.Parents; len(parents) > 0 {
+				beforeSHA = parents[0].GetSHA()
+			}
+			record(RunContextFrom(ctx), Action{
+				Kind:      ActionFileCommitted,
+				Owner:     parsedArgs.Owner,
+				Repo:      parsedArgs.Repo,
+				Branch:    parsedArgs.Branch,
+				Path:      parsedArgs.Path,
+				BeforeSHA: beforeSHA,
+				AfterSHA:  fileResponse.Commit.GetSHA(),
+			})
+
 			result := map[string]any{
 				"path":       parsedArgs.Path,
 				"sha":        fileResponse.Content.GetSHA(),
@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gollama/config"
+	"gollama/githubapi"
+	"gollama/sandbox"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// ChecksFile declares a repository's check commands, read from the base branch so a
+// pull request can't change what it's checked with:
+//
+//	{"checks": ["go build ./...", "go test ./..."]}
+const ChecksFile = ".gollama.json"
+
+// Verification counts failed checks per branch within a run, so the agent gets a fixed
+// number of repair attempts before create_github_pr gives up on the branch.
+type Verification struct {
+	failures map[string]int
+	mu       sync.Mutex
+}
+
+func NewVerification() *Verification {
+	return &Verification{failures: make(map[string]int)}
+}
+
+func (v *Verification) fail(key string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.failures[key]++
+	return v.failures[key]
+}
+
+func (v *Verification) failed(key string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.failures[key]
+}
+
+// CheckReport is the outcome of running the checks on a branch.
+type CheckReport struct {
+	Branch  string           `json:"branch"`
+	SHA     string           `json:"sha"`
+	Passed  bool             `json:"passed"`
+	Repairs int              `json:"repairs"`
+	Checks  []sandbox.Result `json:"checks"`
+}
+
+// Markdown is the section appended to the pull request body.
+func (r *CheckReport) Markdown() string {
+	var b strings.Builder
+	b.WriteString("### Checks\n\n")
+	fmt.Fprintf(&b, "Ran on `%s` (%s) before opening this pull request", r.Branch, shortSHA(r.SHA))
+	if r.Repairs > 0 {
+		fmt.Fprintf(&b, ", passing after %d repair attempt(s)", r.Repairs)
+	}
+	b.WriteString(".\n\n| Check | Result | Time |\n| --- | --- | --- |\n")
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "| `%s` | %s | %.1fs |\n", check.Command, checkOutcome(check), float64(check.DurationMS)/1000)
+	}
+	return b.String()
+}
+
+// failure describes the failing check with its output, for the agent to repair.
+func (r *CheckReport) failure() string {
+	for _, check := range r.Checks {
+		if checkOutcome(check) == "passed" {
+			continue
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "`%s` %s on %s:\n", check.Command, checkOutcome(check), shortSHA(r.SHA))
+		if check.Stdout != "" {
+			fmt.Fprintf(&b, "\nstdout:\n%s\n", check.Stdout)
+		}
+		if check.Stderr != "" {
+			fmt.Fprintf(&b, "\nstderr:\n%s\n", check.Stderr)
+		}
+		return b.String()
+	}
+	return ""
+}
+
+func checkOutcome(check sandbox.Result) string {
+	switch {
+	case check.TimedOut:
+		return "timed out"
+	case check.ExitCode != 0:
+		return fmt.Sprintf("failed (exit code %d)", check.ExitCode)
+	}
+	return "passed"
+}
+
+// verifyBranch runs the repository's checks on head as it is on the remote. It returns
+// nil when the repository has no checks. The checks run in a detached checkout of the
+// session's clone, which leaves the agent's branch and edits alone, or in a throwaway
+// clone outside sessions.
+func verifyBranch(ctx context.Context, owner string, repo string, head string, base string) (*CheckReport, error) {
+	// resolved before cloning, most repositories have nothing to run
+	checks, err := declaredChecks(ctx, owner, repo, base)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+
+	sessionID := RunContextFrom(ctx).SessionID
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("verify:%s/%s:%s:%d", owner, repo, head, time.Now().UnixNano())
+		defer workspaces.Remove(sessionID)
+	}
+
+	ws, _, err := workspaces.Open(ctx, sessionID, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+
+	dir, sha, err := ws.RemoteWorktree(ctx, head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check out %s: %w", head, err)
+	}
+
+	report := &CheckReport{Branch: head, SHA: sha, Passed: true}
+	for _, check := range checks {
+		result, err := commandRunner.Run(ctx, dir, check, 0)
+		if err != nil {
+			// a check the server won't run fails like one that ran and failed
+			result = sandbox.Result{Command: check, ExitCode: -1, Stderr: err.Error()}
+		}
+		report.Checks = append(report.Checks, result)
+
+		// later checks usually depend on earlier ones, like tests on the build
+		if checkOutcome(result) != "passed" {
+			report.Passed = false
+			break
+		}
+	}
+	return report, nil
+}
+
+// declaredChecks reads the checks from the base branch through the API, falling back to
+// the server's.
+func declaredChecks(ctx context.Context, owner string, repo string, base string) ([]string, error) {
+	file, _, resp, err := githubapi.Client().Repositories.GetContents(ctx, owner, repo, ChecksFile, &github.RepositoryContentGetOptions{Ref: base})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return config.ENV.VerifyChecks, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", ChecksFile, err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s on %s is a directory", ChecksFile, base)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", ChecksFile, err)
+	}
+
+	var declared struct {
+		Checks []string `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(content), &declared); err != nil {
+		return nil, fmt.Errorf("failed to parse %s on %s: %w", ChecksFile, base, err)
+	}
+	return declared.Checks, nil
+}
+
+// verifyPullRequest gates create_github_pr on the checks. A failure becomes the tool's
+// error so the agent sees the output, fixes the branch and tries again, until it runs
+// out of repair attempts.
+func verifyPullRequest(ctx context.Context, owner string, repo string, head string, base string) (*CheckReport, error) {
+	verification := RunContextFrom(ctx).Verification
+	if verification == nil {
+		verification = NewVerification()
+	}
+	key := fmt.Sprintf("%s/%s:%s", owner, repo, head)
+	maxRepairs := config.ENV.VerifyMaxRepairs
+
+	if verification.failed(key) > maxRepairs {
+		return nil, fmt.Errorf("checks on %s failed %d times, the pull request was not opened. Stop retrying and tell the user what still fails", head, maxRepairs+1)
+	}
+
+	report, err := verifyBranch(ctx, owner, repo, head, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", head, err)
+	}
+	if report == nil {
+		return nil, nil
+	}
+
+	if report.Passed {
+		report.Repairs = verification.failed(key)
+		return report, nil
+	}
+
+	failures := verification.fail(key)
+	if failures > maxRepairs {
+		return nil, fmt.Errorf("checks on %s still fail after %d repair attempts, the pull request was not opened. Tell the user what fails instead of retrying.\n\n%s", head, maxRepairs, report.failure())
+	}
+	return nil, fmt.Errorf("checks on %s failed, the pull request was not opened. Fix the failure, push the branch and call create_github_pr again (repair attempt %d of %d).\n\n%s", head, failures, maxRepairs, report.failure())
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gollama/config"
+	"gollama/workspace"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var ErrNoSession = errors.New("workspace tools only work inside a chat session")
+
+// workspaces holds the local clones the workspace_* tools work on, one per session and
+// repository. They're the alternative to editing through the GitHub REST API file by file.
+var workspaces = workspace.NewManager(workspace.Options{
+	Root:        config.ENV.WorkspacesDir,
+	RemoteURL:   config.ENV.GitRemoteURL,
+	AuthorName:  config.ENV.GitAuthorName,
+	AuthorEmail: config.ENV.GitAuthorEmail,
+	GithubToken: config.ENV.GithubToken,
+	OnRemove:    goIndexes.Forget,
+})
+
+// dry runs edit and commit in clones of their own, so none of it ends up in a later live push
+const dryRunWorkspaces = "#dry-run"
+
+// RemoveWorkspaces deletes a session's local clones, pushed or not.
+func RemoveWorkspaces(sessionID string) {
+	workspaces.Remove(sessionID)
+	workspaces.Remove(sessionID + dryRunWorkspaces)
+}
+
+// RemoveDryRunWorkspaces throws away the clones the session's dry runs worked in.
+func RemoveDryRunWorkspaces(sessionID string) {
+	workspaces.Remove(sessionID + dryRunWorkspaces)
+}
+
+// workspaceSession keys the run's clones.
+func workspaceSession(run RunContext) string {
+	if run.DryRun {
+		return run.SessionID + dryRunWorkspaces
+	}
+	return run.SessionID
+}
+
+// sessionWorkspace returns the clone of owner/repo opened earlier in the run's session.
+func sessionWorkspace(ctx context.Context, owner string, repo string) (*workspace.Workspace, error) {
+	run := RunContextFrom(ctx)
+	if run.SessionID == "" {
+		return nil, ErrNoSession
+	}
+	return workspaces.Get(workspaceSession(run), owner, repo)
+}
+
+// readWorkspace is sessionWorkspace for read-only tools, which clone the repository
+// themselves when it isn't open yet.
+func readWorkspace(ctx context.Context, owner string, repo string) (*workspace.Workspace, error) {
+	ws, err := sessionWorkspace(ctx, owner, repo)
+	if !errors.Is(err, workspace.ErrNotOpen) {
+		return ws, err
+	}
+
+	ws, _, err = workspaces.Open(ctx, workspaceSession(RunContextFrom(ctx)), owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+	return ws, nil
+}
+
+func workspaceOpenTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_open",
+				Description: "Clone a repository into a local workspace, or refresh the clone opened earlier, and switch to a branch. Call this before any other workspace_* tool.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"branch": map[string]any{
+							"type":        "string",
+							"description": "The branch to work on (defaults to the repository's default branch).",
+						},
+						"create_branch": map[string]any{
+							"type":        "boolean",
+							"description": "Start the branch from the current commit if it doesn't exist yet.",
+						},
+					},
+					"required": []string{"owner", "repo"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type openArgs struct {
+				Owner        string `json:"owner"`
+				Repo         string `json:"repo"`
+				Branch       string `json:"branch"`
+				CreateBranch bool   `json:"create_branch"`
+			}
+
+			var parsedArgs openArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			run := RunContextFrom(ctx)
+			if run.SessionID == "" {
+				return "", ErrNoSession
+			}
+
+			ws, cloned, err := workspaces.Open(ctx, workspaceSession(run), parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", fmt.Errorf("failed to open workspace: %w", err)
+			}
+
+			if parsedArgs.Branch != "" {
+				if err := ws.Checkout(ctx, parsedArgs.Branch, parsedArgs.CreateBranch); err != nil {
+					return "", fmt.Errorf("failed to check out %s: %w", parsedArgs.Branch, err)
+				}
+			}
+
+			status, err := ws.Status(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to read workspace status: %w", err)
+			}
+
+			result := map[string]any{
+				"owner":   parsedArgs.Owner,
+				"repo":    parsedArgs.Repo,
+				"cloned":  cloned,
+				"branch":  status.Branch,
+				"head":    status.Head,
+				"changes": status.Changes,
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal workspace result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
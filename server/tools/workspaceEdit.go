@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Edits only touch the local working tree, so dry runs make them too, in a clone of their
+// own. Nothing leaves the machine until workspace_push.
+
+func workspaceWriteFileTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_write_file",
+				Description: "Create or overwrite a file in the local workspace. Prefer workspace_edit_file for changes to existing files.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"path": map[string]any{
+							"type":        "string",
+							"description": "The path of the file to write.",
+						},
+						"content": map[string]any{
+							"type":        "string",
+							"description": "The complete new content of the file.",
+						},
+					},
+					"required": []string{"owner", "repo", "path", "content"},
+				},
+			},
+		},
+		Mutating: true,
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type writeArgs struct {
+				Owner   string `json:"owner"`
+				Repo    string `json:"repo"`
+				Path    string `json:"path"`
+				Content string `json:"content"`
+			}
+
+			var parsedArgs writeArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			previous, existed, err := ws.Write(parsedArgs.Path, parsedArgs.Content)
+			if err != nil {
+				return "", err
+			}
+
+			result := map[string]any{
+				"path":    parsedArgs.Path,
+				"created": !existed,
+				"diff":    UnifiedDiff(parsedArgs.Path, previous, parsedArgs.Content),
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal write result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func workspaceEditFileTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_edit_file",
+				Description: "Replace a snippet of a file in the local workspace. old_text must match exactly one place in the file, whitespace included.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"path": map[string]any{
+							"type":        "string",
+							"description": "The path of the file to edit.",
+						},
+						"old_text": map[string]any{
+							"type":        "string",
+							"description": "The exact text to replace.",
+						},
+						"new_text": map[string]any{
+							"type":        "string",
+							"description": "The text to put in its place.",
+						},
+					},
+					"required": []string{"owner", "repo", "path", "old_text", "new_text"},
+				},
+			},
+		},
+		Mutating: true,
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type editArgs struct {
+				Owner   string `json:"owner"`
+				Repo    string `json:"repo"`
+				Path    string `json:"path"`
+				OldText string `json:"old_text"`
+				NewText string `json:"new_text"`
+			}
+
+			var parsedArgs editArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.OldText == "" {
+				return "", errors.New("old_text is required, use workspace_write_file to create files")
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			previous, updated, err := ws.Edit(parsedArgs.Path, parsedArgs.OldText, parsedArgs.NewText)
+			if err != nil {
+				return "", err
+			}
+
+			result := map[string]any{
+				"path": parsedArgs.Path,
+				"diff": UnifiedDiff(parsedArgs.Path, previous, updated),
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal edit result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func workspaceCommitTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_commit",
+				Description: "Commit every change in the local workspace to the current branch. The commit stays local until workspace_push.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"message": map[string]any{
+							"type":        "string",
+							"description": "The commit message.",
+						},
+					},
+					"required": []string{"owner", "repo", "message"},
+				},
+			},
+		},
+		Mutating: true,
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type commitArgs struct {
+				Owner   string `json:"owner"`
+				Repo    string `json:"repo"`
+				Message string `json:"message"`
+			}
+
+			var parsedArgs commitArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.Message == "" {
+				return "", errors.New("message is required")
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			changes, err := ws.Status(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to read workspace status: %w", err)
+			}
+
+			sha, err := ws.Commit(ctx, parsedArgs.Message)
+			if err != nil {
+				return "", fmt.Errorf("failed to commit: %w", err)
+			}
+
+			result := map[string]any{
+				"sha":     sha,
+				"branch":  changes.Branch,
+				"message": parsedArgs.Message,
+				"changes": changes.Changes,
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal commit result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func workspacePushTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_push",
+				Description: "Push the current branch of the local workspace to the remote repository, creating the branch there if needed.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+					},
+					"required": []string{"owner", "repo"},
+				},
+			},
+		},
+		Mutating: true,
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type pushArgs struct {
+				Owner string `json:"owner"`
+				Repo  string `json:"repo"`
+			}
+
+			var parsedArgs pushArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			// a dry run asks the remote whether it would take the push
+			_, dry := dryRun(ctx)
+
+			branch, before, after, err := ws.Push(ctx, dry)
+			if err != nil {
+				return "", fmt.Errorf("failed to push: %w", err)
+			}
+
+			result := map[string]any{
+				"branch":     branch,
+				"before_sha": before,
+				"sha":        after,
+				"created":    before == "",
+			}
+			if dry {
+				return marshalDryRun(result)
+			}
+
+			record(RunContextFrom(ctx), Action{
+				Kind:      ActionBranchPushed,
+				Owner:     parsedArgs.Owner,
+				Repo:      parsedArgs.Repo,
+				Branch:    branch,
+				BeforeSHA: before,
+				AfterSHA:  after,
+			})
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal push result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+// undoPush moves a remote branch back to where it was before the run's first push, or
+// deletes it if the run created it. The lease makes git refuse if anyone pushed since.
+func undoPush(ctx context.Context, owner string, repo string, branch string, before string, after string) (string, bool) {
+	name := fmt.Sprintf("%s/%s:%s", owner, repo, branch)
+
+	run := RunContextFrom(ctx)
+	if run.SessionID == "" {
+		return fmt.Sprintf("Couldn't revert the push to %s without its session", name), false
+	}
+
+	// the clone may have been cleaned up since, reopening it clones it again
+	ws, _, err := workspaces.Open(ctx, run.SessionID, owner, repo)
+	if err != nil {
+		return fmt.Sprintf("Couldn't open a workspace to revert the push to %s: %v", name, err), false
+	}
+
+	if err := ws.ResetRemote(ctx, branch, after, before); err != nil {
+		return fmt.Sprintf("Couldn't revert the push to %s, it may have moved since: %v", name, err), false
+	}
+
+	if before == "" {
+		return fmt.Sprintf("Deleted pushed branch %s", name), true
+	}
+	return fmt.Sprintf("Reset pushed branch %s from %s to %s", name, shortSHA(after), shortSHA(before)), true
+}
@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func workspaceReadTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_read",
+				Description: "Read a file from the local workspace, including uncommitted edits, or list a directory.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"path": map[string]any{
+							"type":        "string",
+							"description": "The file or directory path (defaults to the repository root).",
+						},
+					},
+					"required": []string{"owner", "repo"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type readArgs struct {
+				Owner string `json:"owner"`
+				Repo  string `json:"repo"`
+				Path  string `json:"path"`
+			}
+
+			var parsedArgs readArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			isDir, err := ws.IsDir(parsedArgs.Path)
+			if err != nil {
+				return "", err
+			}
+
+			var result map[string]any
+			if isDir {
+				entries, err := ws.List(parsedArgs.Path)
+				if err != nil {
+					return "", err
+				}
+				result = map[string]any{
+					"path":    parsedArgs.Path,
+					"type":    "dir",
+					"entries": entries,
+				}
+			} else {
+				content, truncated, err := ws.Read(parsedArgs.Path)
+				if err != nil {
+					return "", err
+				}
+				result = map[string]any{
+					"path":      parsedArgs.Path,
+					"type":      "file",
+					"content":   content,
+					"truncated": truncated,
+				}
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal read result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
+
+func workspaceSearchTool() Tool {
+	return Tool{
+		Definition: openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "workspace_search",
+				Description: "Search the files of the local workspace for a regular expression, like git grep.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"owner": map[string]any{
+							"type":        "string",
+							"description": "The owner or organization of the repository.",
+						},
+						"repo": map[string]any{
+							"type":        "string",
+							"description": "The name of the repository.",
+						},
+						"pattern": map[string]any{
+							"type":        "string",
+							"description": "The extended regular expression to search for.",
+						},
+						"path": map[string]any{
+							"type":        "string",
+							"description": "Only search below this file or directory.",
+						},
+					},
+					"required": []string{"owner", "repo", "pattern"},
+				},
+			},
+		},
+		Execute: func(ctx context.Context, args string) (string, error) {
+			type searchArgs struct {
+				Owner   string `json:"owner"`
+				Repo    string `json:"repo"`
+				Pattern string `json:"pattern"`
+				Path    string `json:"path"`
+			}
+
+			var parsedArgs searchArgs
+			err := json.Unmarshal([]byte(args), &parsedArgs)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+			}
+			if parsedArgs.Pattern == "" {
+				return "", errors.New("pattern is required")
+			}
+
+			ws, err := sessionWorkspace(ctx, parsedArgs.Owner, parsedArgs.Repo)
+			if err != nil {
+				return "", err
+			}
+
+			matches, truncated, err := ws.Search(ctx, parsedArgs.Pattern, parsedArgs.Path)
+			if err != nil {
+				return "", fmt.Errorf("failed to search workspace: %w", err)
+			}
+
+			result := map[string]any{
+				"pattern":   parsedArgs.Pattern,
+				"matches":   matches,
+				"truncated": truncated,
+			}
+
+			resultBytes, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal search result: %w", err)
+			}
+
+			return string(resultBytes), nil
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gollama/githubapi"
+)
+
+var ErrSenderNotAllowed = errors.New("sender is not allowed to trigger the agent")
+
+// Authorize checks that the job's sender may start a run with the server's GitHub
+// token. Comments and reviews say how their author relates to the repository, which
+// must be one of associations. Other events, like labels, don't, so the sender needs
+// write access to the repository instead.
+func Authorize(ctx context.Context, job *Job, associations []string) error {
+	if job.AuthorAssociation != "" {
+		if slices.Contains(associations, strings.ToUpper(job.AuthorAssociation)) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s is %s", ErrSenderNotAllowed, job.Sender, job.AuthorAssociation)
+	}
+
+	if job.Sender == "" {
+		return fmt.Errorf("%w: the event has no sender", ErrSenderNotAllowed)
+	}
+
+	level, _, err := githubapi.Client().Repositories.GetPermissionLevel(ctx, job.Owner, job.Repo, job.Sender)
+	if err != nil {
+		return fmt.Errorf("failed to check the permission of %s: %w", job.Sender, err)
+	}
+
+	// maintainers show up as write here
+	switch level.GetPermission() {
+	case "admin", "write":
+		return nil
+	}
+	return fmt.Errorf("%w: %s has %s permission", ErrSenderNotAllowed, job.Sender, level.GetPermission())
+}
@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"gollama/githubapi"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// PostResult comments the agent's answer on the issue or pull request that triggered the
+// job and returns the comment's URL.
+func PostResult(ctx context.Context, job *Job, body string) (string, error) {
+	client := githubapi.Client()
+
+	comment, _, err := client.Issues.CreateComment(
+		ctx,
+		job.Owner,
+		job.Repo,
+		job.Number,
+		&github.IssueComment{Body: github.Ptr(body)},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to post comment: %w", err)
+	}
+
+	return comment.GetHTMLURL(), nil
+}
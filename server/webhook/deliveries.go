@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// Deliveries remembers recently handled X-GitHub-Delivery IDs so redelivered events run once.
+type Deliveries struct {
+	seen map[string]time.Time
+	ttl  time.Duration
+	mu   sync.Mutex
+}
+
+func NewDeliveries(ttl time.Duration) *Deliveries {
+	d := &Deliveries{
+		seen: make(map[string]time.Time),
+		ttl:  ttl,
+	}
+
+	go d.cleanup()
+
+	return d
+}
+
+// Seen records the delivery ID and reports whether it was already recorded.
+func (d *Deliveries) Seen(deliveryID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.seen[deliveryID]; exists {
+		return true
+	}
+
+	d.seen[deliveryID] = time.Now()
+	return false
+}
+
+// Forget drops a delivery ID recorded by Seen, so a redelivery of an event that
+// couldn't be queued is handled again.
+func (d *Deliveries) Forget(deliveryID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, deliveryID)
+}
+
+func (d *Deliveries) cleanup() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.mu.Lock()
+		now := time.Now()
+		for id, seenAt := range d.seen {
+			if now.Sub(seenAt) > d.ttl {
+				delete(d.seen, id)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// Rule maps a GitHub event to an agent job. Empty fields match anything.
+type Rule struct {
+	Name          string `json:"name"`
+	Event         string `json:"event"`
+	Action        string `json:"action,omitempty"`
+	Label         string `json:"label,omitempty"`
+	CommentPrefix string `json:"comment_prefix,omitempty"`
+	ReviewState   string `json:"review_state,omitempty"`
+	PullRequest   bool   `json:"pull_request,omitempty"`
+	Prompt        string `json:"prompt"`
+}
+
+// Job is the agent work produced by a matching rule.
+type Job struct {
+	Rule   string
+	Owner  string
+	Repo   string
+	Number int
+	Prompt string
+	// Sender triggered the event. AuthorAssociation is their relationship to the
+	// repository, empty for events that don't carry one, like labels.
+	Sender            string
+	AuthorAssociation string
+}
+
+type promptData struct {
+	Owner   string
+	Repo    string
+	Number  int
+	Title   string
+	Body    string
+	Comment string
+	Sender  string
+	URL     string
+	// not rendered, checked by Authorize
+	association string
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:   "issue-labeled",
+			Event:  "issues",
+			Action: "labeled",
+			Label:  "gollama",
+			Prompt: "Issue #{{.Number}} in {{.Owner}}/{{.Repo}} was labelled for you by {{.Sender}}.\n" +
+				"Title: {{.Title}}\n\n{{.Body}}\n\n" +
+				"Work on this issue: create a branch, make the changes and open a pull request. " +
+				"This was triggered from GitHub, so nobody can approve a plan, go ahead and execute it.",
+		},
+		{
+			Name:          "pr-comment-command",
+			Event:         "issue_comment",
+			Action:        "created",
+			CommentPrefix: "/gollama",
+			PullRequest:   true,
+			Prompt: "{{.Sender}} asked for help on pull request #{{.Number}} in {{.Owner}}/{{.Repo}} ({{.Title}}):\n\n" +
+				"{{.Comment}}\n\n" +
+				"This was triggered from GitHub, so nobody can approve a plan, go ahead and execute it.",
+		},
+		{
+			Name:        "review-changes-requested",
+			Event:       "pull_request_review",
+			Action:      "submitted",
+			ReviewState: "changes_requested",
+			Prompt: "{{.Sender}} requested changes on pull request #{{.Number}} in {{.Owner}}/{{.Repo}} ({{.Title}}):\n\n" +
+				"{{.Comment}}\n\n" +
+				"Address the review by committing to the pull request's branch. " +
+				"This was triggered from GitHub, so nobody can approve a plan, go ahead and execute it.",
+		},
+	}
+}
+
+// LoadRules reads rules from a JSON file, falling back to DefaultRules when path is empty.
+func LoadRules(path string) ([]Rule, error) {
+	if path == "" {
+		return DefaultRules(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		if _, err := template.New(rule.Name).Parse(rule.Prompt); err != nil {
+			return nil, fmt.Errorf("invalid prompt template in rule %q: %w", rule.Name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// Match returns the job for the first rule matching the event, if any.
+func Match(rules []Rule, eventType string, event any) (*Job, error) {
+	for _, rule := range rules {
+		if rule.Event != eventType {
+			continue
+		}
+
+		data, ok := rule.match(event)
+		if !ok {
+			continue
+		}
+
+		tmpl, err := template.New(rule.Name).Parse(rule.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt template in rule %q: %w", rule.Name, err)
+		}
+
+		var prompt bytes.Buffer
+		if err := tmpl.Execute(&prompt, data); err != nil {
+			return nil, fmt.Errorf("failed to render prompt for rule %q: %w", rule.Name, err)
+		}
+
+		return &Job{
+			Rule:              rule.Name,
+			Owner:             data.Owner,
+			Repo:              data.Repo,
+			Number:            data.Number,
+			Prompt:            prompt.String(),
+			Sender:            data.Sender,
+			AuthorAssociation: data.association,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (r Rule) match(event any) (promptData, bool) {
+	switch e := event.(type) {
+	case *github.IssuesEvent:
+		if !r.matchAction(e.GetAction()) || r.PullRequest && !e.GetIssue().IsPullRequest() {
+			return promptData{}, false
+		}
+		if r.Label != "" && e.GetLabel().GetName() != r.Label {
+			return promptData{}, false
+		}
+		return promptData{
+			Owner:  e.GetRepo().GetOwner().GetLogin(),
+			Repo:   e.GetRepo().GetName(),
+			Number: e.GetIssue().GetNumber(),
+			Title:  e.GetIssue().GetTitle(),
+			Body:   e.GetIssue().GetBody(),
+			Sender: e.GetSender().GetLogin(),
+			URL:    e.GetIssue().GetHTMLURL(),
+		}, true
+
+	case *github.IssueCommentEvent:
+		if !r.matchAction(e.GetAction()) || r.PullRequest && !e.GetIssue().IsPullRequest() {
+			return promptData{}, false
+		}
+		comment := strings.TrimSpace(e.GetComment().GetBody())
+		if r.CommentPrefix != "" {
+			args, ok := commandArgs(comment, r.CommentPrefix)
+			if !ok {
+				return promptData{}, false
+			}
+			comment = args
+		}
+		return promptData{
+			Owner:       e.GetRepo().GetOwner().GetLogin(),
+			Repo:        e.GetRepo().GetName(),
+			Number:      e.GetIssue().GetNumber(),
+			Title:       e.GetIssue().GetTitle(),
+			Body:        e.GetIssue().GetBody(),
+			Comment:     comment,
+			Sender:      e.GetSender().GetLogin(),
+			URL:         e.GetComment().GetHTMLURL(),
+			association: e.GetComment().GetAuthorAssociation(),
+		}, true
+
+	case *github.PullRequestReviewEvent:
+		if !r.matchAction(e.GetAction()) {
+			return promptData{}, false
+		}
+		if r.ReviewState != "" && !strings.EqualFold(e.GetReview().GetState(), r.ReviewState) {
+			return promptData{}, false
+		}
+		return promptData{
+			Owner:       e.GetRepo().GetOwner().GetLogin(),
+			Repo:        e.GetRepo().GetName(),
+			Number:      e.GetPullRequest().GetNumber(),
+			Title:       e.GetPullRequest().GetTitle(),
+			Body:        e.GetPullRequest().GetBody(),
+			Comment:     e.GetReview().GetBody(),
+			Sender:      e.GetSender().GetLogin(),
+			URL:         e.GetReview().GetHTMLURL(),
+			association: e.GetReview().GetAuthorAssociation(),
+		}, true
+	}
+
+	return promptData{}, false
+}
+
+func (r Rule) matchAction(action string) bool {
+	return r.Action == "" || r.Action == action
+}
+
+// commandArgs returns what follows prefix when the comment starts with it as a word of
+// its own, so "/gollama fix it" matches "/gollama" but "/gollamafoo" doesn't.
+func commandArgs(comment string, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimLeftFunc(comment, unicode.IsSpace), prefix)
+	if !ok {
+		return "", false
+	}
+	if next, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(next) {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestMatchCommentPrefix(t *testing.T) {
+	rules := []Rule{{
+		Name:          "command",
+		Event:         "issue_comment",
+		CommentPrefix: "/gollama",
+		Prompt:        "{{.Comment}}",
+	}}
+
+	tests := []struct {
+		name    string
+		comment string
+		prompt  string
+		matched bool
+	}{
+		{name: "command", comment: "/gollama fix the tests", prompt: "fix the tests", matched: true},
+		{name: "leading whitespace", comment: "\n  /gollama fix the tests", prompt: "fix the tests", matched: true},
+		{name: "newline after the prefix", comment: "/gollama\nfix the tests", prompt: "fix the tests", matched: true},
+		{name: "prefix alone", comment: "/gollama", prompt: "", matched: true},
+		{name: "longer word", comment: "/gollamafoo fix the tests", matched: false},
+		{name: "prefix quoted later", comment: "As the docs say, `/gollama fix` runs the agent", matched: false},
+		{name: "quoted reply", comment: "> /gollama fix the tests\n\nNot yet please", matched: false},
+		{name: "no prefix", comment: "looks good", matched: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &github.IssueCommentEvent{
+				Comment: &github.IssueComment{Body: github.Ptr(tt.comment)},
+			}
+			job, err := Match(rules, "issue_comment", event)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if (job != nil) != tt.matched {
+				t.Fatalf("matched %v, want %v", job != nil, tt.matched)
+			}
+			if job != nil && job.Prompt != tt.prompt {
+				t.Fatalf("prompt %q, want %q", job.Prompt, tt.prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in dir. Prompts are disabled so a remote asking for
+// credentials fails instead of hanging the run.
+func (m *Manager) git(ctx context.Context, dir string, args ...string) (string, error) {
+	config := []string{
+		"-c", "user.name=" + m.authorName,
+		"-c", "user.email=" + m.authorEmail,
+		"-c", "core.hooksPath=/dev/null",
+	}
+	if m.authHeader != "" {
+		config = append(config, "-c", "http.https://github.com/.extraheader="+m.authHeader)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append(config, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("git %s failed: %s: %w", args[0], redact(message), err)
+	}
+	return stdout.String(), nil
+}
+
+// remoteURL fills owner and repo into the configured remote template.
+func (m *Manager) remoteURL(owner string, repo string) string {
+	return strings.NewReplacer("{owner}", owner, "{repo}", repo).Replace(m.remoteTemplate)
+}
+
+// authHeader is the header git sends to GitHub, so the token never lands in .git/config.
+func authHeader(token string) string {
+	if token == "" {
+		return ""
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return "Authorization: Basic " + credentials
+}
+
+// redact strips credentials from URLs git echoes back in its errors.
+func redact(message string) string {
+	fields := strings.Fields(message)
+	for _, field := range fields {
+		parsed, err := url.Parse(strings.Trim(field, "'\"."))
+		if err != nil || parsed.User == nil {
+			continue
+		}
+		parsed.User = nil
+		message = strings.ReplaceAll(message, strings.Trim(field, "'\"."), parsed.String())
+	}
+	return message
+}
@@ -0,0 +1,184 @@
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotOpen     = errors.New("workspace is not open, call workspace_open first")
+	ErrInvalidName = errors.New("invalid owner or repository name")
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Manager keeps one local clone per session and repository under root.
+type Manager struct {
+	root           string
+	remoteTemplate string
+	authorName     string
+	authorEmail    string
+	authHeader     string
+	onRemove       func(dir string)
+	workspaces     map[string]*Workspace
+	mu             sync.Mutex
+}
+
+type Options struct {
+	Root string
+	// RemoteURL is a template with {owner} and {repo} placeholders
+	RemoteURL   string
+	AuthorName  string
+	AuthorEmail string
+	// GithubToken authenticates clones and pushes to github.com
+	GithubToken string
+	// OnRemove is called with every clone and worktree directory that gets deleted, so
+	// whatever was cached about it can go too
+	OnRemove func(dir string)
+}
+
+func NewManager(opts Options) *Manager {
+	m := &Manager{
+		root:           opts.Root,
+		remoteTemplate: opts.RemoteURL,
+		authorName:     opts.AuthorName,
+		authorEmail:    opts.AuthorEmail,
+		authHeader:     authHeader(opts.GithubToken),
+		onRemove:       opts.OnRemove,
+		workspaces:     make(map[string]*Workspace),
+	}
+
+	go m.cleanupWorkspaces()
+
+	return m
+}
+
+// Open returns the session's clone of owner/repo, cloning it on first use. An existing
+// clone is fetched so it sees branches pushed since.
+func (m *Manager) Open(ctx context.Context, sessionID string, owner string, repo string) (*Workspace, bool, error) {
+	if !namePattern.MatchString(owner) || !namePattern.MatchString(repo) {
+		return nil, false, ErrInvalidName
+	}
+
+	key := workspaceKey(sessionID, owner, repo)
+
+	m.mu.Lock()
+	ws, exists := m.workspaces[key]
+	if !exists {
+		ws = &Workspace{
+			manager:   m,
+			sessionID: sessionID,
+			Owner:     owner,
+			Repo:      repo,
+			dir:       filepath.Join(m.sessionDir(sessionID), owner+"__"+repo),
+		}
+		m.workspaces[key] = ws
+	}
+	m.mu.Unlock()
+
+	ws.lock()
+	defer ws.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(ws.dir, ".git")); err == nil {
+		if _, err := m.git(ctx, ws.dir, "fetch", "--prune", "origin"); err != nil {
+			return nil, false, err
+		}
+		return ws, false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ws.dir), 0o755); err != nil {
+		return nil, false, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	os.RemoveAll(ws.dir) // a clone that died halfway
+
+	if _, err := m.git(ctx, filepath.Dir(ws.dir), "clone", "--origin", "origin", m.remoteURL(owner, repo), ws.dir); err != nil {
+		m.mu.Lock()
+		if m.workspaces[key] == ws {
+			delete(m.workspaces, key)
+		}
+		m.mu.Unlock()
+		return nil, false, err
+	}
+
+	return ws, true, nil
+}
+
+// Get returns a workspace opened earlier in the session.
+func (m *Manager) Get(sessionID string, owner string, repo string) (*Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ws, exists := m.workspaces[workspaceKey(sessionID, owner, repo)]
+	if !exists {
+		return nil, ErrNotOpen
+	}
+	return ws, nil
+}
+
+// Remove deletes every clone the session made.
+func (m *Manager) Remove(sessionID string) {
+	m.mu.Lock()
+	for key, ws := range m.workspaces {
+		if ws.sessionID == sessionID {
+			delete(m.workspaces, key)
+		}
+	}
+	m.mu.Unlock()
+
+	if err := os.RemoveAll(m.sessionDir(sessionID)); err != nil {
+		slog.Error("Failed to remove workspaces of session", "session_id", sessionID, "error", err)
+	}
+	m.removed(m.sessionDir(sessionID))
+}
+
+// removed tells the OnRemove callback that dir and everything below it is gone.
+func (m *Manager) removed(dir string) {
+	if m.onRemove != nil {
+		m.onRemove(dir)
+	}
+}
+
+// sessionDir hashes the session ID, which can contain characters that don't belong in a path.
+func (m *Manager) sessionDir(sessionID string) string {
+	hash := sha256.Sum256([]byte(sessionID))
+	return filepath.Join(m.root, hex.EncodeToString(hash[:8]))
+}
+
+func (m *Manager) cleanupWorkspaces() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.mu.Lock()
+		var idle []*Workspace
+		now := time.Now()
+		for key, ws := range m.workspaces {
+			// same lifetime as the chat sessions that own them
+			if ws.idleSince(now) > 2*time.Hour {
+				delete(m.workspaces, key)
+				idle = append(idle, ws)
+			}
+		}
+		m.mu.Unlock()
+
+		for _, ws := range idle {
+			if err := os.RemoveAll(ws.dir); err != nil {
+				slog.Error("Failed to remove workspace", "session_id", ws.sessionID, "dir", ws.dir, "error", err)
+			}
+			m.removed(ws.dir)
+		}
+	}
+}
+
+func workspaceKey(sessionID string, owner string, repo string) string {
+	return sessionID + "/" + owner + "/" + repo
+}
@@ -0,0 +1,595 @@
+package workspace
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+const (
+	// bigger files are cut off when read, the model can't use more anyway
+	maxReadBytes     = 100 * 1024
+	maxSearchMatches = 200
+	// each is a full checkout, the least recently used ones go beyond this
+	maxWorktrees = 5
+)
+
+var (
+	ErrOutsideWorkspace = errors.New("path is outside the workspace")
+	ErrTextNotFound     = errors.New("old_text was not found in the file")
+	ErrTextNotUnique    = errors.New("old_text matches more than once, include more surrounding lines")
+	ErrNothingToCommit  = errors.New("nothing to commit, the working tree is clean")
+)
+
+// Workspace is a local clone of one repository. Git operations on it are serialized.
+type Workspace struct {
+	Owner     string
+	Repo      string
+	manager   *Manager
+	sessionID string
+	dir       string
+	// unix nanoseconds, read without mu so cleanup never waits on a long git command
+	lastUsed atomic.Int64
+	mu       sync.Mutex
+}
+
+type Entry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Size int64  `json:"size,omitempty"`
+}
+
+type Match struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+type Status struct {
+	Branch  string   `json:"branch"`
+	Head    string   `json:"head"`
+	Changes []string `json:"changes"`
+}
+
+// Dir is the working tree on disk.
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Read returns a file's content, truncated for very large files.
+func (w *Workspace) Read(path string) (string, bool, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	full, err := w.resolve(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	content, err := os.ReadFile(full)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(content) > maxReadBytes {
+		return string(content[:maxReadBytes]), true, nil
+	}
+	return string(content), false, nil
+}
+
+// IsDir reports whether path is a directory in the working tree.
+func (w *Workspace) IsDir(path string) (bool, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	full, err := w.resolve(path)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("%s doesn't exist", path)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return info.IsDir(), nil
+}
+
+// List returns the entries of a directory, without the .git directory.
+func (w *Workspace) List(path string) ([]Entry, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	full, err := w.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := os.ReadDir(full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", path, err)
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		if item.Name() == ".git" {
+			continue
+		}
+		rel, _ := filepath.Rel(w.dir, filepath.Join(full, item.Name()))
+		entry := Entry{Path: filepath.ToSlash(rel), Type: "file"}
+		if item.IsDir() {
+			entry.Type = "dir"
+		} else if info, err := item.Info(); err == nil {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Search greps tracked and untracked files for a regular expression.
+func (w *Workspace) Search(ctx context.Context, pattern string, path string) ([]Match, bool, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	args := []string{"grep", "-n", "-I", "-E", "--untracked", "--no-color", "-e", pattern}
+	if path != "" {
+		if _, err := w.resolve(path); err != nil {
+			return nil, false, err
+		}
+		args = append(args, "--", path)
+	}
+
+	out, err := w.manager.git(ctx, w.dir, args...)
+	if err != nil {
+		// git grep exits 1 when nothing matches
+		if strings.Contains(err.Error(), "exit status 1") {
+			return []Match{}, false, nil
+		}
+		return nil, false, err
+	}
+
+	matches := make([]Match, 0)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		file, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		lineNumber, text, ok := strings.Cut(rest, ":")
+		if !ok {
+			continue
+		}
+		var line int
+		fmt.Sscanf(lineNumber, "%d", &line)
+
+		if len(matches) == maxSearchMatches {
+			return matches, true, nil
+		}
+		matches = append(matches, Match{Path: file, Line: line, Text: strings.TrimSpace(text)})
+	}
+	return matches, false, nil
+}
+
+// Write creates or replaces a file, creating parent directories as needed. It returns
+// what the file held before and whether it existed.
+func (w *Workspace) Write(path string, content string) (string, bool, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	full, err := w.resolve(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	previous, err := os.ReadFile(full)
+	existed := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return "", false, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		return "", false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return string(previous), existed, nil
+}
+
+// Edit replaces the single occurrence of oldText in a file and returns the old content.
+func (w *Workspace) Edit(path string, oldText string, newText string) (string, string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	full, err := w.resolve(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := os.ReadFile(full)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	switch strings.Count(string(content), oldText) {
+	case 0:
+		return "", "", ErrTextNotFound
+	case 1:
+	default:
+		return "", "", ErrTextNotUnique
+	}
+
+	updated := strings.Replace(string(content), oldText, newText, 1)
+	if err := os.WriteFile(full, []byte(updated), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return string(content), updated, nil
+}
+
+// Checkout switches to branch. A branch that doesn't exist locally is started from the
+// remote branch of the same name, or from the current HEAD when create is set.
+func (w *Workspace) Checkout(ctx context.Context, branch string, create bool) error {
+	w.lock()
+	defer w.mu.Unlock()
+
+	if err := w.checkBranch(ctx, branch); err != nil {
+		return err
+	}
+
+	if _, err := w.manager.git(ctx, w.dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err := w.manager.git(ctx, w.dir, "checkout", branch)
+		return err
+	}
+	if _, err := w.manager.git(ctx, w.dir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err == nil {
+		_, err := w.manager.git(ctx, w.dir, "checkout", "-b", branch, "--track", "origin/"+branch)
+		return err
+	}
+	if !create {
+		return fmt.Errorf("branch %s doesn't exist, set create to start it", branch)
+	}
+
+	_, err := w.manager.git(ctx, w.dir, "checkout", "-b", branch)
+	return err
+}
+
+// Commit stages every change in the working tree and commits it, returning the new SHA.
+func (w *Workspace) Commit(ctx context.Context, message string) (string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.manager.git(ctx, w.dir, "add", "-A"); err != nil {
+		return "", err
+	}
+
+	status, err := w.manager.git(ctx, w.dir, "status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(status) == "" {
+		return "", ErrNothingToCommit
+	}
+
+	if _, err := w.manager.git(ctx, w.dir, "commit", "--no-verify", "-m", message); err != nil {
+		return "", err
+	}
+	return w.head(ctx)
+}
+
+// Push pushes the current branch to origin. It returns the remote branch's SHA before
+// the push, empty when the branch is new on the remote, and the pushed SHA. A dry run
+// asks the remote whether it would accept the push without updating it.
+func (w *Workspace) Push(ctx context.Context, dryRun bool) (string, string, string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	branch, err := w.branch(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	head, err := w.head(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var before string
+	out, err := w.manager.git(ctx, w.dir, "ls-remote", "--heads", "origin", "refs/heads/"+branch)
+	if err != nil {
+		return "", "", "", err
+	}
+	if fields := strings.Fields(out); len(fields) > 0 {
+		before = fields[0]
+	}
+
+	args := []string{"push", "--porcelain", "origin", "HEAD:refs/heads/" + branch}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	if _, err := w.manager.git(ctx, w.dir, args...); err != nil {
+		return "", "", "", err
+	}
+
+	if !dryRun {
+		// keep origin/<branch> in step for later checkouts and status
+		w.manager.git(ctx, w.dir, "fetch", "--", "origin", branch)
+	}
+	return branch, before, head, nil
+}
+
+// Worktree checks out ref, a remote branch, tag or commit, in a separate directory and
+// returns it with the commit's SHA. The working tree and its edits stay untouched, and
+// a commit that was checked out before is reused. Only the maxWorktrees most recently
+// used checkouts are kept.
+func (w *Workspace) Worktree(ctx context.Context, ref string) (string, string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+	return w.worktree(ctx, ref)
+}
+
+// RemoteWorktree checks out branch exactly as it is on the remote in a separate
+// directory, like Worktree, so what runs there is what a pull request would contain.
+// Local commits and edits on the branch don't count.
+func (w *Workspace) RemoteWorktree(ctx context.Context, branch string) (string, string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	if err := w.checkBranch(ctx, branch); err != nil {
+		return "", "", err
+	}
+	return w.worktree(ctx, "refs/remotes/origin/"+branch)
+}
+
+// worktree must be called with w.mu held.
+func (w *Workspace) worktree(ctx context.Context, ref string) (string, string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", "", fmt.Errorf("invalid ref %q", ref)
+	}
+
+	// branches may have moved since the clone was opened, commits don't
+	if !shaPattern.MatchString(ref) {
+		if _, err := w.manager.git(ctx, w.dir, "fetch", "--prune", "--tags", "origin"); err != nil {
+			return "", "", err
+		}
+	}
+
+	var sha string
+	for _, candidate := range []string{"origin/" + ref, ref} {
+		out, err := w.manager.git(ctx, w.dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			sha = strings.TrimSpace(out)
+			break
+		}
+	}
+	if sha == "" {
+		return "", "", fmt.Errorf("ref %s not found", ref)
+	}
+
+	// inside .git, so it goes with the clone and never shows up in its working tree
+	dir := filepath.Join(w.worktreesDir(), sha)
+	now := time.Now()
+	if _, err := os.Stat(dir); err == nil {
+		// the modification time orders worktrees by last use for pruning
+		os.Chtimes(dir, now, now)
+		return dir, sha, nil
+	}
+
+	if _, err := w.manager.git(ctx, w.dir, "worktree", "add", "--detach", dir, sha); err != nil {
+		return "", "", err
+	}
+	os.Chtimes(dir, now, now)
+
+	w.pruneWorktrees(ctx)
+	return dir, sha, nil
+}
+
+func (w *Workspace) worktreesDir() string {
+	return filepath.Join(w.dir, ".git", "gollama-worktrees")
+}
+
+// pruneWorktrees removes the least recently used worktrees beyond maxWorktrees. Must be
+// called with w.mu held.
+func (w *Workspace) pruneWorktrees(ctx context.Context) {
+	entries, err := os.ReadDir(w.worktreesDir())
+	if err != nil || len(entries) <= maxWorktrees {
+		return
+	}
+
+	type worktree struct {
+		dir     string
+		modTime time.Time
+	}
+	var worktrees []worktree
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		worktrees = append(worktrees, worktree{dir: filepath.Join(w.worktreesDir(), entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(worktrees, func(i, j int) bool {
+		return worktrees[i].modTime.After(worktrees[j].modTime)
+	})
+
+	for _, old := range worktrees[min(maxWorktrees, len(worktrees)):] {
+		if _, err := w.manager.git(ctx, w.dir, "worktree", "remove", "--force", old.dir); err != nil {
+			slog.WarnContext(ctx, "Failed to remove worktree", "dir", old.dir, "error", err)
+			continue
+		}
+		w.manager.removed(old.dir)
+	}
+}
+
+// Blob is a file of a commit's tree.
+type Blob struct {
+	SHA  string
+	Size int64
+}
+
+// Blobs lists the files of commit sha by path, submodules left out.
+func (w *Workspace) Blobs(ctx context.Context, sha string) (map[string]Blob, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	if !shaPattern.MatchString(sha) {
+		return nil, fmt.Errorf("invalid commit %q", sha)
+	}
+
+	out, err := w.manager.git(ctx, w.dir, "ls-tree", "-r", "-z", "--long", sha)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make(map[string]Blob)
+	for _, entry := range strings.Split(out, "\x00") {
+		// <mode> <type> <sha> <size>\t<path>
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		blobs[path] = Blob{SHA: fields[2], Size: size}
+	}
+	return blobs, nil
+}
+
+// ResetRemote moves the remote branch back to before, or deletes it when before is empty,
+// but only while it still points at expected.
+func (w *Workspace) ResetRemote(ctx context.Context, branch string, expected string, before string) error {
+	w.lock()
+	defer w.mu.Unlock()
+
+	lease := fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", branch, expected)
+	if before == "" {
+		_, err := w.manager.git(ctx, w.dir, "push", lease, "origin", "--delete", branch)
+		return err
+	}
+
+	_, err := w.manager.git(ctx, w.dir, "push", lease, "origin", before+":refs/heads/"+branch)
+	return err
+}
+
+func (w *Workspace) Status(ctx context.Context) (Status, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	branch, err := w.branch(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	head, err := w.head(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	out, err := w.manager.git(ctx, w.dir, "status", "--porcelain")
+	if err != nil {
+		return Status{}, err
+	}
+
+	changes := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if line != "" {
+			changes = append(changes, line)
+		}
+	}
+	sort.Strings(changes)
+	return Status{Branch: branch, Head: head, Changes: changes}, nil
+}
+
+// Diff is the uncommitted change in the working tree, new files included.
+func (w *Workspace) Diff(ctx context.Context) (string, error) {
+	w.lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.manager.git(ctx, w.dir, "add", "-A", "--intent-to-add"); err != nil {
+		return "", err
+	}
+	return w.manager.git(ctx, w.dir, "diff", "--no-color", "HEAD")
+}
+
+// checkBranch rejects names git would take for something else than a branch, like
+// options. Branch names reach git from the model's tool arguments.
+func (w *Workspace) checkBranch(ctx context.Context, branch string) error {
+	if strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	if _, err := w.manager.git(ctx, w.dir, "check-ref-format", "--branch", branch); err != nil {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	return nil
+}
+
+func (w *Workspace) branch(ctx context.Context) (string, error) {
+	out, err := w.manager.git(ctx, w.dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (w *Workspace) head(ctx context.Context) (string, error) {
+	out, err := w.manager.git(ctx, w.dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// resolve maps a repository path to the working tree, refusing anything that would end
+// up outside it or inside .git, including through symlinks.
+func (w *Workspace) resolve(path string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(path))
+	if clean == "/.git" || strings.HasPrefix(clean, "/.git/") {
+		return "", ErrOutsideWorkspace
+	}
+	full := filepath.Join(w.dir, clean)
+
+	// the file may not exist yet, so check the deepest existing parent
+	existing := full
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	root, err := filepath.EvalSymlinks(w.dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", ErrOutsideWorkspace
+	}
+	return full, nil
+}
+
+// lock takes the workspace lock and marks it as used.
+func (w *Workspace) lock() {
+	w.mu.Lock()
+	w.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (w *Workspace) idleSince(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, w.lastUsed.Load()))
+}
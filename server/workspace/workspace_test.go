@@ -0,0 +1,352 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRemote creates a bare acme/demo repository with one commit on main and returns a
+// manager whose clones come from it.
+func newRemote(t *testing.T) (*Manager, string) {
+	t.Helper()
+
+	remotes := t.TempDir()
+	bare := filepath.Join(remotes, "acme", "demo.git")
+	seed := t.TempDir()
+
+	run(t, "", "init", "--bare", "--initial-branch=main", bare)
+	run(t, seed, "init", "--initial-branch=main")
+	if err := os.WriteFile(filepath.Join(seed, "README.md"), []byte("# demo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, seed, "add", "-A")
+	run(t, seed, "commit", "-m", "initial")
+	run(t, seed, "push", bare, "main")
+
+	m := NewManager(Options{
+		Root:        t.TempDir(),
+		RemoteURL:   filepath.Join(remotes, "{owner}", "{repo}.git"),
+		AuthorName:  "Test",
+		AuthorEmail: "test@example.com",
+	})
+	return m, bare
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	args = append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// remoteRef returns the SHA of a branch on the bare remote, empty when it doesn't exist.
+func remoteRef(t *testing.T, bare string, branch string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", "--git-dir", bare, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCloneEditCommitPush(t *testing.T) {
+	ctx := context.Background()
+	m, bare := newRemote(t)
+
+	ws, cloned, err := m.Open(ctx, "session", "acme", "demo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !cloned {
+		t.Fatal("Open didn't clone on first use")
+	}
+
+	if err := ws.Checkout(ctx, "feature", false); err == nil {
+		t.Fatal("Checkout started a missing branch without create")
+	}
+	if err := ws.Checkout(ctx, "feature", true); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+
+	if _, err := ws.Commit(ctx, "empty"); !errors.Is(err, ErrNothingToCommit) {
+		t.Fatalf("Commit on a clean tree: got %v, want ErrNothingToCommit", err)
+	}
+
+	if _, existed, err := ws.Write("docs/hello.txt", "hello\n"); err != nil || existed {
+		t.Fatalf("Write: existed %v, err %v", existed, err)
+	}
+	if _, _, err := ws.Edit("README.md", "# demo", "# demo project"); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	content, _, err := ws.Read("README.md")
+	if err != nil || content != "# demo project\n" {
+		t.Fatalf("Read after Edit: %q, %v", content, err)
+	}
+
+	first, err := ws.Commit(ctx, "add hello")
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if _, _, _, err := ws.Push(ctx, true); err != nil {
+		t.Fatalf("dry-run Push: %v", err)
+	}
+	if sha := remoteRef(t, bare, "feature"); sha != "" {
+		t.Fatalf("dry-run Push created the remote branch at %s", sha)
+	}
+
+	branch, before, after, err := ws.Push(ctx, false)
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if branch != "feature" || before != "" || after != first {
+		t.Fatalf("Push returned %s %q %s, want feature \"\" %s", branch, before, after, first)
+	}
+	if sha := remoteRef(t, bare, "feature"); sha != first {
+		t.Fatalf("remote feature is at %q, want %s", sha, first)
+	}
+
+	ws.Write("docs/hello.txt", "hello again\n")
+	second, err := ws.Commit(ctx, "update hello")
+	if err != nil {
+		t.Fatalf("second Commit: %v", err)
+	}
+	if _, before, _, err := ws.Push(ctx, false); err != nil || before != first {
+		t.Fatalf("second Push: before %q, err %v", before, err)
+	}
+
+	// the lease refuses when the branch isn't where the caller thinks it is
+	if err := ws.ResetRemote(ctx, "feature", first, ""); err == nil {
+		t.Fatal("ResetRemote ignored a stale expected SHA")
+	}
+
+	if err := ws.ResetRemote(ctx, "feature", second, first); err != nil {
+		t.Fatalf("ResetRemote to before: %v", err)
+	}
+	if sha := remoteRef(t, bare, "feature"); sha != first {
+		t.Fatalf("remote feature is at %q after reset, want %s", sha, first)
+	}
+
+	if err := ws.ResetRemote(ctx, "feature", first, ""); err != nil {
+		t.Fatalf("ResetRemote delete: %v", err)
+	}
+	if sha := remoteRef(t, bare, "feature"); sha != "" {
+		t.Fatalf("remote feature still exists at %s", sha)
+	}
+}
+
+func TestRemoteWorktreeIgnoresLocalWork(t *testing.T) {
+	ctx := context.Background()
+	m, bare := newRemote(t)
+
+	ws, _, err := m.Open(ctx, "session", "acme", "demo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ws.Write("local.txt", "not pushed\n")
+	if _, err := ws.Commit(ctx, "local only"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	ws.Write("dirty.txt", "not committed\n")
+
+	dir, sha, err := ws.RemoteWorktree(ctx, "main")
+	if err != nil {
+		t.Fatalf("RemoteWorktree: %v", err)
+	}
+	if want := remoteRef(t, bare, "main"); sha != want {
+		t.Fatalf("RemoteWorktree checked out %s, want remote main %s", sha, want)
+	}
+	for _, name := range []string{"local.txt", "dirty.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s leaked into the remote worktree", name)
+		}
+	}
+
+	status, err := ws.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Branch != "main" || len(status.Changes) != 1 {
+		t.Fatalf("working tree changed: %+v", status)
+	}
+}
+
+func TestWorktreesArePruned(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newRemote(t)
+	var removed []string
+	m.onRemove = func(dir string) { removed = append(removed, dir) }
+
+	ws, _, err := m.Open(ctx, "session", "acme", "demo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	var dirs []string
+	for i := range maxWorktrees + 2 {
+		ws.Write("counter.txt", strings.Repeat("x", i+1))
+		sha, err := ws.Commit(ctx, "commit")
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		dir, _, err := ws.Worktree(ctx, sha)
+		if err != nil {
+			t.Fatalf("Worktree: %v", err)
+		}
+		dirs = append(dirs, dir)
+
+		// keeps the first one in use, so the second is the oldest
+		if i > 0 {
+			if _, _, err := ws.Worktree(ctx, filepath.Base(dirs[0])); err != nil {
+				t.Fatalf("Worktree reuse: %v", err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(ws.worktreesDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxWorktrees {
+		t.Fatalf("got %d worktrees, want %d", len(entries), maxWorktrees)
+	}
+	if len(removed) != 2 || removed[0] != dirs[1] || removed[1] != dirs[2] {
+		t.Fatalf("removed %v, want %v", removed, dirs[1:3])
+	}
+	if _, err := os.Stat(dirs[0]); err != nil {
+		t.Fatalf("the recently used worktree was removed: %v", err)
+	}
+	if out := run(t, ws.Dir(), "worktree", "list"); strings.Contains(out, dirs[1]) {
+		t.Fatalf("git still lists the removed worktree:\n%s", out)
+	}
+
+	m.Remove("session")
+	if last := removed[len(removed)-1]; last != m.sessionDir("session") {
+		t.Fatalf("Remove reported %s, want the session directory", last)
+	}
+}
+
+func TestBranchNamesAreNotOptions(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newRemote(t)
+
+	ws, _, err := m.Open(ctx, "session", "acme", "demo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	branch := "--upload-pack=touch " + marker + "; git-upload-pack"
+
+	if _, _, err := ws.RemoteWorktree(ctx, branch); err == nil {
+		t.Error("RemoteWorktree accepted an option as branch")
+	}
+	if err := ws.Checkout(ctx, branch, true); err == nil {
+		t.Error("Checkout accepted an option as branch")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the injected upload-pack command ran")
+	}
+}
+
+func TestOpenRejectsInvalidNames(t *testing.T) {
+	m, _ := newRemote(t)
+
+	for _, name := range []string{"", "..", "-x", "a/b", "a b"} {
+		if _, _, err := m.Open(context.Background(), "session", "acme", name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Open(%q): got %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newRemote(t)
+
+	ws, _, err := m.Open(ctx, "session", "acme", "demo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(ws.Dir(), "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(ws.Dir(), "secret-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("README.md", filepath.Join(ws.Dir(), "readme-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		outside bool
+		// dangling links can't be resolved at all, which refuses them just the same
+		fails bool
+	}{
+		{path: "README.md"},
+		{path: "new/dir/file.go"},
+		{path: "/README.md"},
+		{path: "readme-link"},
+		// cleaned against the root, so they stay inside
+		{path: "../README.md"},
+		{path: "../../etc/passwd"},
+		{path: "docs/../../../etc/passwd"},
+		{path: ".git", outside: true},
+		{path: ".git/config", outside: true},
+		{path: "../.git/config", outside: true},
+		{path: "escape", outside: true},
+		{path: "escape/file.txt", outside: true},
+		{path: "secret-link", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			full, err := ws.resolve(tt.path)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("resolved to %s, want an error", full)
+				}
+				return
+			}
+			if tt.outside {
+				if !errors.Is(err, ErrOutsideWorkspace) {
+					t.Fatalf("got %q, %v, want ErrOutsideWorkspace", full, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if !strings.HasPrefix(full, ws.Dir()+string(filepath.Separator)) {
+				t.Fatalf("resolved to %s, outside %s", full, ws.Dir())
+			}
+		})
+	}
+
+	if _, _, err := ws.Write("escape/planted.txt", "x"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Fatalf("Write through a symlink: got %v, want ErrOutsideWorkspace", err)
+	}
+	if _, _, err := ws.Write("secret-link", "x"); err == nil {
+		t.Fatal("Write through a dangling symlink succeeded")
+	}
+	for _, name := range []string{"planted.txt", "secret"} {
+		if _, err := os.Stat(filepath.Join(outside, name)); err == nil {
+			t.Fatalf("Write followed a symlink out of the workspace to %s", name)
+		}
+	}
+}